@@ -0,0 +1,163 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ErrMissingCredentials - Returned by the built-in authenticators when they
+// have no credentials to add.
+var ErrMissingCredentials = errors.New("missing credentials")
+
+// sharedRequestTimeout - Timeout of the token and login requests shared by
+// concurrent calls, which do not follow the context of any of them.
+const sharedRequestTimeout = 30 * time.Second
+
+// Authenticator - Adds credentials to the requests sent by a Client. It is
+// called for every attempt, retries included, just before the request is
+// sent, and must be safe for concurrent use.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// Reauthenticator - Optional interface of an Authenticator whose
+// credentials can go stale. It is called with every response; when it
+// returns true, the request is authenticated and sent once more, at most
+// once per call.
+type Reauthenticator interface {
+	Reauthenticate(req *http.Request, res *http.Response) bool
+}
+
+// AuthenticatorFunc - Adapter to use an ordinary function as Authenticator.
+type AuthenticatorFunc func(req *http.Request) error
+
+// Authenticate - Calls f(req).
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// noAuthentication - Authenticator of the calls that must not carry the
+// credentials of their Client.
+var noAuthentication = AuthenticatorFunc(func(*http.Request) error { return nil })
+
+// BasicAuth - Authenticator sending a user and password with the Basic
+// scheme of RFC 7617.
+type BasicAuth struct {
+	User     string
+	Password string
+}
+
+// Authenticate - Sets the Authorization header.
+func (a *BasicAuth) Authenticate(req *http.Request) error {
+	if a.User == "" {
+		return ErrMissingCredentials
+	}
+	req.SetBasicAuth(a.User, a.Password)
+	return nil
+}
+
+// BearerToken - Authenticator sending a static token with the Bearer scheme
+// of RFC 6750.
+type BearerToken struct {
+	Token string
+}
+
+// Authenticate - Sets the Authorization header.
+func (a *BearerToken) Authenticate(req *http.Request) error {
+	if a.Token == "" {
+		return ErrMissingCredentials
+	}
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+// APIKey - Authenticator sending a key in the header called Name or, when
+// InQuery is set, in the query parameter called Name.
+type APIKey struct {
+	Name    string
+	Key     string
+	InQuery bool
+}
+
+// Authenticate - Sets the header or query parameter holding the key.
+func (a *APIKey) Authenticate(req *http.Request) error {
+	if a.Name == "" || a.Key == "" {
+		return ErrMissingCredentials
+	}
+	if !a.InQuery {
+		req.Header.Set(a.Name, a.Key)
+		return nil
+	}
+	query := req.URL.Query()
+	query.Set(a.Name, a.Key)
+	req.URL.RawQuery = query.Encode()
+	return nil
+}
+
+// authenticator - Returns the Authenticator of the call, the one set on api
+// taking precedence over the one of restClient.
+func (restClient *Client) authenticator(api *BaseAPI) Authenticator {
+	if api.Authenticator() != nil {
+		return api.Authenticator()
+	}
+	return restClient.Authenticator
+}
+
+// queryKey - Returns the name of the query parameter the authenticator of
+// the call sends its key in, if any.
+func (restClient *Client) queryKey(api *BaseAPI) string {
+	if key, ok := restClient.authenticator(api).(*APIKey); ok && key.InQuery {
+		return key.Name
+	}
+	return ""
+}
+
+// callRedactor - Returns the Redactor of the call, also masking the query
+// parameter holding the key of its authenticator, whatever its name.
+func (restClient *Client) callRedactor(api *BaseAPI) *Redactor {
+	redactor := restClient.redactor()
+	name := restClient.queryKey(api)
+	if name == "" {
+		return redactor
+	}
+	withKey := *redactor
+	withKey.queryParams = append(withKey.queryParams[:len(withKey.queryParams):len(withKey.queryParams)], name)
+	return &withKey
+}
+
+// hideQueryKey - Returns rawURL with the key of the authenticator of the
+// call masked, when it is sent in the query.
+func (restClient *Client) hideQueryKey(api *BaseAPI, rawURL string) string {
+	name := restClient.queryKey(api)
+	if name == "" {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	if _, ok := query[name]; !ok {
+		return rawURL
+	}
+	query.Set(name, restClient.redactor().mask())
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// reauthenticate - Tells whether req, answered with res, is to be sent again
+// with fresh credentials.
+func (restClient *Client) reauthenticate(api *BaseAPI, req *http.Request, res *http.Response) bool {
+	reauthenticator, ok := restClient.authenticator(api).(Reauthenticator)
+	return ok && reauthenticator.Reauthenticate(req, res) && rewindable(req)
+}
+
+// detachedContext - Returns a context keeping the values of ctx but not its
+// cancellation, for a request shared by several calls, bounded by
+// sharedRequestTimeout.
+func detachedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), sharedRequestTimeout)
+}
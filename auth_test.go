@@ -0,0 +1,150 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newEchoAuthServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("X-Api-Key") + "|" + r.URL.RawQuery))
+	}))
+}
+
+func TestAuthenticators(t *testing.T) {
+
+	ts := newEchoAuthServer()
+	defer ts.Close()
+
+	tests := []struct {
+		authenticator Authenticator
+		expected      string
+	}{
+		{&BasicAuth{User: "nsxUser", Password: "nsxPass"}, "Basic bnN4VXNlcjpuc3hQYXNz||page=1"},
+		{&BearerToken{Token: "t0k3n"}, "Bearer t0k3n||page=1"},
+		{&APIKey{Name: "X-Api-Key", Key: "k3y"}, "|k3y|page=1"},
+		{&APIKey{Name: "api_key", Key: "k3y", InQuery: true}, "||api_key=k3y&page=1"},
+	}
+	for _, test := range tests {
+		client := Client{URL: ts.URL, Authenticator: test.authenticator}
+		out := new(string)
+		assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/?page=1", nil, out, nil)))
+		assert.Equal(t, test.expected, *out)
+	}
+}
+
+func TestAuthenticatorPrecedence(t *testing.T) {
+
+	ts := newEchoAuthServer()
+	defer ts.Close()
+
+	client := Client{URL: ts.URL, User: "nsxUser", Password: "nsxPass"}
+	out := new(string)
+	assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/", nil, out, nil)))
+	assert.Equal(t, "Basic bnN4VXNlcjpuc3hQYXNz||", *out)
+
+	client = Client{URL: ts.URL, User: "nsxUser", Password: "nsxPass", Authenticator: &BearerToken{Token: "client"}}
+	assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/", nil, out, nil)))
+	assert.Equal(t, "Bearer client||", *out)
+
+	api := NewBaseAPI(http.MethodGet, "/", nil, out, nil)
+	api.SetAuthenticator(&BearerToken{Token: "call"})
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, "Bearer call||", *out)
+}
+
+func TestAuthenticatorAppliedOnEveryAttempt(t *testing.T) {
+
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(t, "Bearer t0k3n", r.Header.Get("Authorization"))
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	authentications := 0
+	authenticator := AuthenticatorFunc(func(req *http.Request) error {
+		authentications++
+		req.Header.Set("Authorization", "Bearer t0k3n")
+		return nil
+	})
+	client := Client{URL: ts.URL, Authenticator: authenticator, RetryPolicy: testRetryPolicy()}
+	api := NewBaseAPI(http.MethodGet, "/", nil, nil, nil)
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, 2, api.Attempts())
+	assert.Equal(t, 2, authentications)
+}
+
+func TestAuthenticatorError(t *testing.T) {
+
+	ts := newEchoAuthServer()
+	defer ts.Close()
+
+	client := Client{URL: ts.URL, Authenticator: &BearerToken{}}
+	err := client.Do(NewBaseAPI(http.MethodGet, "/", nil, nil, nil))
+	assert.True(t, errors.Is(err, ErrMissingCredentials))
+}
+
+func TestAPIKeyInQueryNotLoggedOnTransportError(t *testing.T) {
+
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := Client{
+		URL:           "http://127.0.0.1:1",
+		Authenticator: &APIKey{Name: "key", Key: "s3cr3t-k3y", InQuery: true},
+		Logger:        logger,
+		RetryPolicy:   testRetryPolicy(),
+	}
+	err := client.Do(NewBaseAPI(http.MethodGet, "/", nil, nil, nil))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "key=%2A%2A%2A")
+	assert.NotContains(t, err.Error(), "s3cr3t-k3y")
+	assert.Contains(t, out.String(), "Error executing request")
+	assert.Contains(t, out.String(), "key=%2A%2A%2A")
+	assert.NotContains(t, out.String(), "s3cr3t-k3y")
+}
+
+func TestAPIKeyInQueryNotInHTTPError(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "s3cr3t-k3y", r.URL.Query().Get("key"))
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL, Authenticator: &APIKey{Name: "key", Key: "s3cr3t-k3y", InQuery: true}}
+	api := NewBaseAPI(http.MethodGet, "/items?page=2", nil, nil, nil)
+	err := client.Do(api)
+	var httpErr *HTTPError
+	assert.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, ts.URL+"/items?key=%2A%2A%2A&page=2", httpErr.URL)
+	assert.Equal(t, ts.URL+"/items?key=%2A%2A%2A&page=2", api.FinalURL())
+}
+
+func TestRequestBodyClosedWhenAuthenticationFails(t *testing.T) {
+
+	var body io.ReadCloser
+	failing := AuthenticatorFunc(func(req *http.Request) error {
+		body = req.Body
+		return ErrMissingCredentials
+	})
+	client := Client{URL: "http://127.0.0.1:1", Authenticator: failing}
+	form := NewMultipart()
+	form.AddField("name", "value")
+	api := NewBaseAPI(http.MethodPost, "/", nil, nil, nil)
+	api.SetMultipart(form)
+	assert.True(t, errors.Is(client.Do(api), ErrMissingCredentials))
+
+	_, err := body.Read(make([]byte, 1))
+	assert.Equal(t, io.ErrClosedPipe, err)
+}
@@ -0,0 +1,98 @@
+package rest
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+const streamContentType = "application/octet-stream"
+
+// streamBody - Sets r as the body of req, sent with a fixed length when it is
+// known or can be found by seeking, and chunked otherwise. When r is an
+// io.Seeker the body is rewound through GetBody, so that retries and
+// redirects send it again from where it started; each attempt then reads r
+// through its own streamReader.
+func streamBody(req *http.Request, r io.Reader, length int64) error {
+
+	req.Body = ioutil.NopCloser(r)
+	req.GetBody = nil
+
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		req.ContentLength = unknownLength(length)
+		return nil
+	}
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if length < 0 {
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		length = end - start
+	}
+	req.ContentLength = unknownLength(length)
+	body := &streamReader{r: r}
+	req.Body = body
+	var mu sync.Mutex
+	req.GetBody = func() (io.ReadCloser, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// The previous body may still be read by the transport: it must be
+		// done with r before r is rewound.
+		body.Close()
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		body = &streamReader{r: r}
+		return body, nil
+	}
+	if length == 0 {
+		req.Body = http.NoBody
+	}
+	return nil
+}
+
+// streamReader - Reads a rewindable stream for a single attempt. Close waits
+// for a Read in progress to return, and fails the next ones, so that the
+// stream is never read and rewound at the same time. The stream itself is
+// left open, as it belongs to the caller.
+type streamReader struct {
+	mu     sync.Mutex
+	r      io.Reader
+	closed bool
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, io.ErrClosedPipe
+	}
+	return s.r.Read(p)
+}
+
+// Close - Stops the reads of the stream.
+func (s *streamReader) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return nil
+}
+
+// unknownLength - Returns length, with -1 standing for an unknown length as
+// http.Request expects.
+func unknownLength(length int64) int64 {
+	if length < 0 {
+		return -1
+	}
+	return length
+}
@@ -0,0 +1,179 @@
+package rest
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type uploadRecord struct {
+	Body             string
+	ContentLength    int64
+	TransferEncoding []string
+	ContentType      string
+}
+
+func newUploadServer(record *uploadRecord, statuses ...int) *httptest.Server {
+	var calls int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		*record = uploadRecord{
+			Body:             string(data),
+			ContentLength:    r.ContentLength,
+			TransferEncoding: r.TransferEncoding,
+			ContentType:      r.Header.Get("Content-Type"),
+		}
+		call := int(atomic.AddInt32(&calls, 1))
+		if call <= len(statuses) {
+			w.WriteHeader(statuses[call-1])
+		}
+	}))
+}
+
+func TestStreamSeekableRequestBody(t *testing.T) {
+
+	var record uploadRecord
+	ts := newUploadServer(&record)
+	defer ts.Close()
+
+	reader := strings.NewReader("skip:file content")
+	reader.Seek(5, io.SeekStart)
+	client := Client{URL: ts.URL}
+	assert.Nil(t, client.Do(NewBaseAPI(http.MethodPut, "/upload", reader, nil, nil)))
+	assert.Equal(t, "file content", record.Body)
+	assert.Equal(t, int64(12), record.ContentLength)
+	assert.Nil(t, record.TransferEncoding)
+	assert.Equal(t, "application/octet-stream", record.ContentType)
+}
+
+func TestStreamRequestBodyWithUnknownLength(t *testing.T) {
+
+	var record uploadRecord
+	ts := newUploadServer(&record)
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	reader := io.MultiReader(strings.NewReader("part one, "), strings.NewReader("part two"))
+	api := NewBaseAPI(http.MethodPost, "/upload", reader, nil, nil)
+	api.SetContentType("text/csv")
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, "part one, part two", record.Body)
+	assert.Equal(t, []string{"chunked"}, record.TransferEncoding)
+	assert.Equal(t, "text/csv", record.ContentType)
+}
+
+func TestStreamRequestBodyWithKnownLength(t *testing.T) {
+
+	var record uploadRecord
+	ts := newUploadServer(&record)
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	reader := io.MultiReader(strings.NewReader("0123456789"))
+	api := NewBaseAPI(http.MethodPost, "/upload", reader, nil, nil)
+	api.SetRequestContentLength(10)
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, "0123456789", record.Body)
+	assert.Equal(t, int64(10), record.ContentLength)
+	assert.Nil(t, record.TransferEncoding)
+}
+
+func TestStreamRequestBodyIsRewoundOnRetry(t *testing.T) {
+
+	var record uploadRecord
+	ts := newUploadServer(&record, http.StatusServiceUnavailable)
+	defer ts.Close()
+
+	client := Client{URL: ts.URL, RetryPolicy: testRetryPolicy()}
+	api := NewBaseAPI(http.MethodPut, "/upload", bytes.NewReader([]byte("payload")), nil, nil)
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, 2, api.Attempts())
+	assert.Equal(t, "payload", record.Body)
+}
+
+// slowSeeker - io.ReadSeeker recording whether it is sought while a slow
+// Read is in progress.
+type slowSeeker struct {
+	*bytes.Reader
+	started    chan struct{}
+	once       sync.Once
+	reading    int32
+	overlapped int32
+}
+
+func (s *slowSeeker) Read(p []byte) (int, error) {
+	atomic.StoreInt32(&s.reading, 1)
+	defer atomic.StoreInt32(&s.reading, 0)
+	s.once.Do(func() { close(s.started) })
+	time.Sleep(50 * time.Millisecond)
+	return s.Reader.Read(p)
+}
+
+func (s *slowSeeker) Seek(offset int64, whence int) (int64, error) {
+	if atomic.LoadInt32(&s.reading) == 1 {
+		atomic.StoreInt32(&s.overlapped, 1)
+	}
+	return s.Reader.Seek(offset, whence)
+}
+
+func TestStreamRequestBodyRewoundAfterLastRead(t *testing.T) {
+
+	r := &slowSeeker{Reader: bytes.NewReader([]byte("payload")), started: make(chan struct{})}
+	req, _ := http.NewRequest(http.MethodPut, "http://host/upload", nil)
+	assert.Nil(t, streamBody(req, r, -1))
+
+	previous := req.Body
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		previous.Read(make([]byte, 1))
+	}()
+	<-r.started
+	body, err := req.GetBody()
+	assert.Nil(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&r.overlapped))
+	<-done
+
+	_, err = previous.Read(make([]byte, 1))
+	assert.Equal(t, io.ErrClosedPipe, err)
+	data, err := ioutil.ReadAll(body)
+	assert.Nil(t, err)
+	assert.Equal(t, "payload", string(data))
+}
+
+func TestStreamRequestBodyIsNotRetriedWhenNotSeekable(t *testing.T) {
+
+	var record uploadRecord
+	ts := newUploadServer(&record, http.StatusServiceUnavailable)
+	defer ts.Close()
+
+	client := Client{URL: ts.URL, RetryPolicy: testRetryPolicy()}
+	api := NewBaseAPI(http.MethodPut, "/upload", io.MultiReader(strings.NewReader("payload")), nil, nil)
+	assert.NotNil(t, client.Do(api))
+	assert.Equal(t, 1, api.Attempts())
+}
+
+func TestStreamRequestBodyFollowsRedirect(t *testing.T) {
+
+	var record uploadRecord
+	target := newUploadServer(&record)
+	defer target.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		http.Redirect(w, r, target.URL+"/moved", http.StatusTemporaryRedirect)
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	api := NewBaseAPI(http.MethodPost, "/upload", strings.NewReader("redirected"), nil, nil)
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, "redirected", record.Body)
+}
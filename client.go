@@ -2,68 +2,113 @@ package rest
 
 import (
 	"bytes"
-	"crypto/tls"
-	"encoding/json"
-	"encoding/xml"
+	"context"
 	"errors"
 	"fmt"
-	"github.com/sky-uk/go-rest-api/contenttype"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
+	"sync"
 	"time"
 )
 
+// defaultContentType - Content-Type sent when Headers does not set one.
+const defaultContentType = "text/plain"
+
 // Client struct.
+// A Client must not be modified once it has been used and is then safe for
+// concurrent use by multiple goroutines; every per-call result is recorded on
+// the BaseAPI passed to Do.
 type Client struct {
-	URL        string
-	User       string
-	Password   string
-	IgnoreSSL  bool
-	Debug      bool
-	Headers    map[string]string
-	Timeout    time.Duration // in seconds
+	URL       string
+	User      string
+	Password  string
+	IgnoreSSL bool
+	Debug     bool
+	Headers   map[string]string
+	Timeout   time.Duration // in seconds
+
+	// Deprecated: StatusCode is no longer set, as a Client may be shared by
+	// concurrent calls. Use BaseAPI.StatusCode() instead.
 	StatusCode int
-}
 
-func (restClient *Client) formatRequestPayload(api *BaseAPI) (io.Reader, error) {
+	// Connection pool settings, used when neither HTTPClient nor Transport
+	// is set. Zero values select the Default* constants.
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	TLSSessionCacheSize int
 
-	var requestPayload io.Reader
+	// Transport - Optional RoundTripper used instead of the built-in pooled
+	// transport. IgnoreSSL and the pool settings do not apply to it.
+	Transport http.RoundTripper
 
-	var reqBytes []byte
-	if api.RequestObject() != nil {
-		var err error
-		contentType := contenttype.GetType(restClient.Headers["Content-Type"])
+	// HTTPClient - Optional http.Client used as is for every call; it takes
+	// precedence over Transport and Timeout.
+	HTTPClient *http.Client
 
-		switch contentType {
+	// RetryPolicy - Optional policy for sending failed calls again.
+	RetryPolicy *RetryPolicy
 
-		case "json":
-			reqBytes, err = json.Marshal(api.RequestObject())
-			if err != nil {
-				log.Fatal("[ERROR] ", err)
-				return nil, err
-			}
+	// Logger - Optional destination of the client diagnostics. When nil they
+	// go to the standard log package if Debug is on, and nowhere otherwise.
+	// Request and response payloads are only logged when Debug is on.
+	Logger Logger
 
-		case "xml":
-			reqBytes, err = xml.Marshal(api.RequestObject())
-			if err != nil {
-				log.Fatal("[ERROR] ", err)
-				return nil, err
-			}
+	// Authenticator - Optional credentials added to every request, taking
+	// precedence over User and Password.
+	Authenticator Authenticator
+
+	// Redactor - Masks secrets in the debug traces, DefaultRedactor when nil.
+	Redactor *Redactor
+
+	// Codecs - Optional codecs taking precedence over DefaultCodecs.
+	Codecs *CodecRegistry
+
+	// MaxResponseBodySize - Maximum number of bytes of a response payload
+	// read into memory (0 = no limit). Payloads streamed to an io.Writer or
+	// *io.ReadCloser response object are not limited.
+	MaxResponseBodySize int64
+
+	httpClientOnce sync.Once
+	httpClient     *http.Client
+}
+
+func (restClient *Client) formatRequestPayload(clog *callLog, api *BaseAPI, contentTypeHeader string) (io.Reader, error) {
+
+	var requestPayload io.Reader
 
-		case "octet-stream", "plain", "html":
-			reqBytes = api.RequestObject().([]byte)
+	if api.Multipart() != nil {
+		if restClient.Debug {
+			clog.debug("Request payload", "payload", "multipart", "boundary", api.Multipart().Boundary())
+		}
+		return nil, nil
+	}
+	if reader, ok := api.RequestObject().(io.Reader); ok {
+		if restClient.Debug {
+			clog.debug("Request payload", "payload", "streamed", "content_length", api.RequestContentLength())
+		}
+		return reader, nil
+	}
 
+	var reqBytes []byte
+	if api.RequestObject() != nil {
+		codec := restClient.codec(contentTypeHeader)
+		if codec == nil {
+			clog.error("Content type not supported", "content_type", contentTypeHeader)
+			return nil, &EncodeError{ContentType: contentTypeHeader, Err: ErrNoCodec}
+		}
+		var err error
+		reqBytes, err = codec.Marshal(api.RequestObject())
+		if err != nil {
+			clog.error("Error encoding request payload", "content_type", contentTypeHeader, "error", err)
+			return nil, &EncodeError{ContentType: contentTypeHeader, Err: err}
 		}
 		requestPayload = bytes.NewReader(reqBytes)
 	}
 
 	if restClient.Debug {
-		log.Println("[TRACE] --------------------------------------------------------------")
-		log.Println("[TRACE] Request payload:")
-		log.Println("[TRACE] ", string(reqBytes))
-		log.Println("[TRACE] --------------------------------------------------------------")
+		payload := restClient.redactor().Payload(contentTypeHeader, reqBytes)
+		clog.debug("Request payload", "payload", string(payload))
 	}
 
 	return requestPayload, nil
@@ -71,146 +116,359 @@ func (restClient *Client) formatRequestPayload(api *BaseAPI) (io.Reader, error)
 
 // Do - makes the API call.
 func (restClient *Client) Do(api *BaseAPI) error {
+	return restClient.DoContext(context.Background(), api)
+}
 
-	requestURL := fmt.Sprintf("%s%s", restClient.URL, api.Endpoint())
-	if restClient.Debug {
-		log.Printf("[TRACE] Going to perform request:[%s] %s\n", api.Method(), requestURL)
-	}
+// DoContext - makes the API call, aborting it as soon as ctx is cancelled or
+// its deadline expires. The returned error then wraps ctx.Err(), so
+// errors.Is(err, context.Canceled) and errors.Is(err, context.DeadlineExceeded)
+// can be used to tell the two apart.
+func (restClient *Client) DoContext(ctx context.Context, api *BaseAPI) error {
 
-	if restClient.Headers == nil {
-		restClient.Headers = make(map[string]string)
+	requestURL, err := restClient.requestURL(api)
+	if err != nil {
+		restClient.logger().Error("Error building the request URL", "method", api.Method(), "endpoint", api.Endpoint(), "error", err)
+		return err
 	}
+	redactor := restClient.callRedactor(api)
+	clog := newCallLog(restClient.logger(), "method", api.Method(), "url", redactor.URL(requestURL), "request_id", newRequestID())
+	clog.debug("Going to perform request")
 
-	_, ok := restClient.Headers["Content-Type"]
-	if !ok {
-		restClient.Headers["Content-Type"] = "text/plain"
-	}
+	contentType := restClient.contentType(api)
 
-	requestPayload, err := restClient.formatRequestPayload(api)
+	requestPayload, err := restClient.formatRequestPayload(clog, api, contentType)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest(api.Method(), requestURL, requestPayload)
+	var req *http.Request
+	if api.Multipart() != nil {
+		req, err = http.NewRequestWithContext(ctx, api.Method(), requestURL, nil)
+		if err == nil {
+			err = multipartBody(req, api.Multipart())
+		}
+	} else if _, ok := api.RequestObject().(io.Reader); ok {
+		req, err = http.NewRequestWithContext(ctx, api.Method(), requestURL, nil)
+		if err == nil {
+			err = streamBody(req, requestPayload, api.RequestContentLength())
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, api.Method(), requestURL, requestPayload)
+	}
 	if err != nil {
-		log.Println("[ERROR] Error building the request: ", err)
+		clog.error("Error building the request", "error", redactor.Error(err))
+		if req != nil {
+			closeRequestBody(req)
+		}
 		return err
 	}
 
-	if restClient.User != "" {
+	if restClient.User != "" && restClient.authenticator(api) == nil {
 		req.SetBasicAuth(restClient.User, restClient.Password)
 	}
 
 	for headerKey, headerValue := range restClient.Headers {
 		req.Header.Set(headerKey, headerValue)
 	}
+	for headerKey, headerValues := range api.Header() {
+		req.Header[http.CanonicalHeaderKey(headerKey)] = headerValues
+	}
+	req.Header.Set("Content-Type", contentType)
 
-	tr := &http.Transport{
-		TLSClientConfig:   &tls.Config{InsecureSkipVerify: restClient.IgnoreSSL},
-		MaxIdleConns:      10,
-		IdleConnTimeout:   30 * time.Second,
-		DisableKeepAlives: true,
+	explicitAccept := req.Header.Get("Accept")
+	if explicitAccept == "" && api.ResponseObject() != nil && !isStreamTarget(api.ResponseObject()) {
+		if accept := restClient.accept(api.ResponseObject()); len(accept) > 0 {
+			req.Header.Set("Accept", accept.String())
+		}
 	}
 
-	httpClient := &http.Client{
-		Transport: tr,
-		Timeout:   restClient.Timeout * time.Second,
+	if restClient.Debug {
+		clog.debug("Request headers", "headers", redactor.Header(req.Header))
 	}
 
-	res, err := httpClient.Do(req)
+	start := time.Now()
+	res, err := restClient.send(ctx, clog, api, req)
 	if err != nil {
-		log.Println("[ERROR] Error executing request: ", err)
+		api.SetDuration(time.Since(start))
 		return err
 	}
-	defer res.Body.Close()
-	restClient.StatusCode = res.StatusCode
-	return restClient.handleResponse(api, res)
+	err = restClient.handleResponse(ctx, clog, api, res, explicitAccept)
+	api.SetDuration(time.Since(start))
+	clog.debug("Request completed", "status", api.StatusCode(), "attempt", api.Attempts(), "duration", api.Duration())
+	return err
 }
 
-func (restClient *Client) handleResponse(apiObj *BaseAPI, res *http.Response) error {
+// requestURL - Returns the URL of the api endpoint: the endpoint template
+// expanded and joined to the Client URL, with the query object and query
+// parameters of the api added.
+func (restClient *Client) requestURL(api *BaseAPI) (string, error) {
+	endpoint, err := expandEndpoint(api.Endpoint(), api.PathParams())
+	if err != nil {
+		return "", err
+	}
+	u, err := joinURL(restClient.URL, endpoint)
+	if err != nil {
+		return "", err
+	}
+	if api.QueryObject() == nil && len(api.Query()) == 0 {
+		return u.String(), nil
+	}
+
+	query := u.Query()
+	if api.QueryObject() != nil {
+		encoded, err := EncodeQuery(api.QueryObject())
+		if err != nil {
+			return "", fmt.Errorf("encoding query object: %w", err)
+		}
+		for name, values := range encoded {
+			query[name] = values
+		}
+	}
+	for name, values := range api.Query() {
+		query[name] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// contentType - Returns the Content-Type of the api request payload: the
+// one set on the api, else the one of the api or Client headers, else
+// defaultContentType.
+func (restClient *Client) contentType(api *BaseAPI) string {
+	if api.Multipart() != nil {
+		return api.Multipart().ContentType()
+	}
+	if api.ContentType() != "" {
+		return api.ContentType()
+	}
+	if values, ok := api.Header()["Content-Type"]; ok && len(values) > 0 {
+		return values[0]
+	}
+	if contentType, ok := restClient.Headers["Content-Type"]; ok {
+		return contentType
+	}
+	if _, ok := api.RequestObject().(io.Reader); ok {
+		return streamContentType
+	}
+	return defaultContentType
+}
+
+// send - Sends req, retrying it as allowed by the RetryPolicy, and returns
+// the response to handle.
+func (restClient *Client) send(ctx context.Context, clog *callLog, api *BaseAPI, req *http.Request) (*http.Response, error) {
+
+	policy := restClient.RetryPolicy
+	httpClient := restClient.getHTTPClient()
+	redactor := restClient.callRedactor(api)
+	var waited time.Duration
+	reauthenticated := false
+	for attempt := 1; ; attempt++ {
+		api.SetAttempts(attempt)
+		attemptReq, err := rewindRequest(req, attempt)
+		if err != nil {
+			clog.error("Error rewinding the request payload", "attempt", attempt, "error", redactor.Error(err))
+			closeRequestBody(req)
+			return nil, err
+		}
+		if authenticator := restClient.authenticator(api); authenticator != nil {
+			if err := authenticator.Authenticate(attemptReq); err != nil {
+				clog.error("Error authenticating the request", "attempt", attempt, "error", redactor.Error(err))
+				closeRequestBody(attemptReq)
+				return nil, err
+			}
+		}
+
+		var delay time.Duration
+		start := time.Now()
+		res, err := httpClient.Do(attemptReq)
+		if err != nil {
+			err = redactor.urlError(err)
+			if ctx.Err() != nil || !policy.canRetry(req, attempt) || !policy.retryableError(err) {
+				clog.error("Error executing request", "attempt", attempt, "duration", time.Since(start), "error", redactor.Error(err))
+				return nil, contextError(ctx, err)
+			}
+			delay = policy.backoff(attempt)
+			clog.warn("Request failed, retrying", "attempt", attempt, "duration", time.Since(start), "delay", delay, "error", redactor.Error(err))
+		} else {
+			rateLimit := parseRateLimit(res.Header, time.Now())
+			api.SetRateLimit(rateLimit)
+			if restClient.reauthenticate(api, attemptReq, res) && !reauthenticated {
+				clog.warn("Credentials rejected, authenticating again", "attempt", attempt, "status", res.StatusCode)
+				reauthenticated = true
+				discardResponse(res)
+				continue
+			}
+			if !policy.canRetry(req, attempt) || !policy.retryableStatus(res.StatusCode) {
+				return res, nil
+			}
+			delay = policy.backoff(attempt)
+			if wait, ok := rateLimit.Wait(time.Now()); ok && policy.RetryAfterBudget > 0 {
+				if waited+wait > policy.RetryAfterBudget {
+					clog.warn("Requested retry delay exceeds the retry budget", "attempt", attempt, "status", res.StatusCode, "delay", wait)
+					return res, nil
+				}
+				waited += wait
+				delay = wait
+			}
+			clog.warn("Request answered with a retryable status, retrying", "attempt", attempt, "status", res.StatusCode, "duration", time.Since(start), "delay", delay)
+			discardResponse(res)
+		}
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// closeRequestBody - Closes the body of a request given up before being
+// sent, which the transport would otherwise have closed.
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// contextError - makes sure err wraps the context error when the failure was
+// caused by ctx being cancelled or timing out.
+func contextError(ctx context.Context, err error) error {
+	ctxErr := ctx.Err()
+	if ctxErr == nil || errors.Is(err, ctxErr) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ctxErr, err)
+}
+
+func (restClient *Client) handleResponse(ctx context.Context, clog *callLog, apiObj *BaseAPI, res *http.Response, explicitAccept string) error {
 
 	apiObj.SetStatusCode(res.StatusCode)
-	bodyText, err := ioutil.ReadAll(res.Body)
+	apiObj.SetResponseHeader(res.Header)
+	apiObj.SetProto(res.Proto)
+	if res.Request != nil {
+		apiObj.SetFinalURL(restClient.hideQueryKey(apiObj, res.Request.URL.String()))
+	}
+	if restClient.Debug {
+		clog.debug("Response headers", "status", res.StatusCode, "headers", restClient.redactor().Header(res.Header))
+	}
+
+	failed := apiObj.StatusCode() >= http.StatusBadRequest
+	if !failed {
+		switch target := apiObj.ResponseObject().(type) {
+		case *io.ReadCloser:
+			// The caller reads and closes the body.
+			apiObj.SetContentLength(res.ContentLength)
+			*target = res.Body
+			return nil
+		case io.Writer:
+			return restClient.streamResponse(ctx, clog, apiObj, res, target)
+		}
+	}
+	defer res.Body.Close()
+
+	bodyText, err := restClient.readResponse(res)
 	if err != nil {
-		log.Println("[ERROR] Error reading response: ", err)
+		clog.error("Error reading response", "status", res.StatusCode, "error", err)
+		return contextError(ctx, err)
+	}
+	if err := ctx.Err(); err != nil {
 		return err
 	}
+	apiObj.SetTrailer(res.Trailer)
+	if res.ContentLength >= 0 {
+		apiObj.SetContentLength(res.ContentLength)
+	} else {
+		apiObj.SetContentLength(int64(len(bodyText)))
+	}
+
+	var errorObject interface{}
 
 	if len(bodyText) > 0 {
-		contentType := contenttype.GetType(res.Header.Get("Content-Type"))
-		if restClient.Debug {
-		}
+		contentType := res.Header.Get("Content-Type")
 
 		if restClient.Debug {
-			log.Println("[TRACE] --------------------------------------------------------------")
-			log.Println("[TRACE] Response content type: ", contentType)
-			log.Println("[TRACE] Response payload:")
-			log.Println("[TRACE] ", string(bodyText))
-			log.Println("[TRACE] --------------------------------------------------------------")
+			payload := restClient.redactor().Payload(contentType, bodyText)
+			clog.debug("Response payload", "status", res.StatusCode, "content_type", contentType, "payload", string(payload))
 		}
 		apiObj.SetRawResponse(bodyText)
 
-		switch contentType {
-		case "json":
-			if apiObj.StatusCode() >= http.StatusOK && apiObj.StatusCode() < http.StatusBadRequest {
-				err := json.Unmarshal(bodyText, apiObj.ResponseObject())
-				if err != nil {
-					log.Println("[ERROR] Error unmarshalling response: ", err)
-					return err
-				}
-			} else {
-				if apiObj.ErrorObject() != nil {
-					err := json.Unmarshal(bodyText, apiObj.ErrorObject())
-					if err != nil {
-						log.Printf("[ERROR] Error unmarshalling error response:\n%v", err)
-						return err
-					}
-				}
-				errMsg := fmt.Sprintf("Response status code: %d", apiObj.StatusCode())
-				return errors.New(errMsg)
+		if !failed {
+			if err := restClient.checkAcceptable(contentType, apiObj.ResponseObject(), explicitAccept); err != nil {
+				clog.error("Response content type not acceptable", "status", res.StatusCode, "content_type", contentType, "error", err)
+				return err
 			}
-
-		case "xml":
-			if apiObj.StatusCode() >= http.StatusOK && apiObj.StatusCode() < http.StatusBadRequest {
-				err := xml.Unmarshal(bodyText, apiObj.ResponseObject())
-				if err != nil {
-					log.Println("[ERROR] Error unmarshalling response: ", err)
-					return err
-				}
+			if err := restClient.decodePayload(clog, contentType, bodyText, apiObj.ResponseObject()); err != nil {
+				clog.error("Error unmarshalling response", "status", res.StatusCode, "error", err)
+				return err
+			}
+		} else if apiObj.ErrorObject() != nil {
+			if err := restClient.decodePayload(clog, contentType, bodyText, apiObj.ErrorObject()); err != nil {
+				clog.error("Error unmarshalling error response", "status", res.StatusCode, "error", err)
 			} else {
-				if apiObj.ErrorObject() != nil {
-					err := xml.Unmarshal(bodyText, apiObj.ErrorObject())
-					if err != nil {
-						log.Printf("[ERROR] Error unmarshalling error response:\n%v", err)
-					}
-				}
-				errMsg := fmt.Sprintf("Response status code: %d", apiObj.StatusCode())
-				return errors.New(errMsg)
+				errorObject = apiObj.ErrorObject()
 			}
+		}
+	}
 
-		case "octet-stream":
-			if apiObj.ResponseObject() != nil {
-				if pstream, is := apiObj.ResponseObject().(*[]byte); is {
-					*pstream = bodyText
-				} else {
-					log.Println("[WARN] Response object expected to be *[]byte")
-				}
-			}
+	if failed {
+		return newHTTPError(apiObj, res, bodyText, errorObject)
+	}
+	return nil
+}
 
-		case "plain", "html":
-			if apiObj.ResponseObject() != nil {
-				if pstream, is := apiObj.ResponseObject().(*string); is {
-					*pstream = string(bodyText)
-				} else {
-					log.Println("[WARN] Response object expected to be *string")
-				}
-			}
+// readResponse - Reads the whole response payload into memory, failing with
+// a *ResponseTooLargeError when it exceeds MaxResponseBodySize.
+func (restClient *Client) readResponse(res *http.Response) ([]byte, error) {
+	limit := restClient.MaxResponseBodySize
+	if limit <= 0 {
+		return ioutil.ReadAll(res.Body)
+	}
+	if res.ContentLength > limit {
+		return nil, &ResponseTooLargeError{Limit: limit, ContentLength: res.ContentLength}
+	}
+	bodyText, err := ioutil.ReadAll(io.LimitReader(res.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(bodyText)) > limit {
+		return nil, &ResponseTooLargeError{Limit: limit, ContentLength: res.ContentLength}
+	}
+	return bodyText, nil
+}
 
-		default:
-			log.Printf("[WARN] Content type %s not supported yet", contentType)
-		}
-	} else {
+// streamResponse - Copies a successful response payload to writer without
+// buffering it.
+func (restClient *Client) streamResponse(ctx context.Context, clog *callLog, apiObj *BaseAPI, res *http.Response, writer io.Writer) error {
+	defer res.Body.Close()
+
+	written, err := io.Copy(writer, res.Body)
+	apiObj.SetContentLength(written)
+	if err != nil {
+		clog.error("Error streaming response", "status", res.StatusCode, "written", written, "error", err)
+		return contextError(ctx, err)
+	}
+	apiObj.SetTrailer(res.Trailer)
+	clog.debug("Response payload streamed", "status", res.StatusCode, "written", written)
+	return nil
+}
+
+// decodePayload - Decodes a response payload of the given content type into
+// target with the matching codec. A target the codec cannot handle, or a
+// content type without codec, only produces a warning.
+func (restClient *Client) decodePayload(clog *callLog, contentType string, payload []byte, target interface{}) error {
+	if target == nil {
+		return nil
 	}
 
+	codec := restClient.codec(contentType)
+	if codec == nil {
+		clog.warn("Content type not supported yet", "content_type", contentType)
+		return nil
+	}
+	if err := codec.Unmarshal(payload, target); err != nil {
+		if errors.Is(err, ErrUnsupportedObject) {
+			clog.warn("Response object not supported by codec", "content_type", contentType, "error", err)
+			return nil
+		}
+		return &DecodeError{ContentType: contentType, Err: err}
+	}
 	return nil
 }
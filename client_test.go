@@ -1,14 +1,22 @@
 package rest
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 var user = "nsxUser"
@@ -329,3 +337,237 @@ func TestHttpRequestPayload(t *testing.T) {
 	assert.Equal(t, "Fooo", respBody.FieldOne)
 	assert.Equal(t, "Baar", respBody.FieldTwo)
 }
+
+func TestDoContextCancelled(t *testing.T) {
+
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	client := Client{URL: ts.URL}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	api := NewBaseAPI(http.MethodGet, "/", nil, new(string), nil)
+	err := client.DoContext(ctx, api)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.False(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestDoContextDeadlineWhileReadingBody(t *testing.T) {
+
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	client := Client{URL: ts.URL}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	api := NewBaseAPI(http.MethodGet, "/", nil, new(string), nil)
+	err := client.DoContext(ctx, api)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, http.StatusOK, api.StatusCode())
+}
+
+func TestPerRequestHeadersQueryAndContentType(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/xml", r.Header.Get("Content-Type"))
+		assert.Equal(t, "request", r.Header.Get("X-Override"))
+		assert.Equal(t, "client", r.Header.Get("X-Client"))
+		assert.Equal(t, []string{"a", "b"}, r.Header["X-Multi"])
+		assert.Equal(t, "2", r.URL.Query().Get("page"))
+		assert.Equal(t, "name", r.URL.Query().Get("sort"))
+		assert.Equal(t, []string{"x", "y"}, r.URL.Query()["tag"])
+
+		var reqPayload XMLFoo
+		assert.Nil(t, xml.NewDecoder(r.Body).Decode(&reqPayload))
+		assert.Equal(t, "bar", reqPayload.Foo)
+	}))
+	defer ts.Close()
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+		"X-Override":   "client",
+		"X-Client":     "client",
+	}
+	client := Client{URL: ts.URL, Headers: headers}
+
+	api := NewBaseAPI(http.MethodPost, "/items?sort=name", XMLFoo{Foo: "bar"}, nil, nil)
+	api.SetContentType("application/xml")
+	api.SetHeader("X-Override", "request")
+	api.Header().Add("X-Multi", "a")
+	api.Header().Add("X-Multi", "b")
+	api.Query().Set("page", "2")
+	api.Query().Add("tag", "x")
+	api.Query().Add("tag", "y")
+
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, "client", client.Headers["X-Override"])
+}
+
+func TestContentTypeFromRequestHeader(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var reqPayload ReqBody
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&reqPayload))
+		assert.Equal(t, "Foo", reqPayload.FieldOne)
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	api := NewBaseAPI(http.MethodPost, "/", ReqBody{FieldOne: "Foo"}, nil, nil)
+	api.SetHeader("Content-Type", "application/json")
+	assert.Nil(t, client.Do(api))
+}
+
+func TestResponseMetadata(t *testing.T) {
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusFound)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Location", "/new/1")
+		w.Write([]byte("streamed"))
+		w.(http.Flusher).Flush()
+		w.Header().Set("X-Checksum", "abc")
+	})
+	mux.HandleFunc("/fixed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("fixed"))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	api := NewBaseAPI(http.MethodGet, "/old", nil, new(string), nil)
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, `"v1"`, api.ResponseHeader().Get("ETag"))
+	assert.Equal(t, "/new/1", api.ResponseHeader().Get("Location"))
+	assert.Equal(t, "abc", api.Trailer().Get("X-Checksum"))
+	assert.Equal(t, ts.URL+"/new", api.FinalURL())
+	assert.Equal(t, "HTTP/1.1", api.Proto())
+	assert.Equal(t, int64(len("streamed")), api.ContentLength())
+	assert.True(t, api.Duration() > 0)
+
+	api = NewBaseAPI(http.MethodGet, "/fixed", nil, new(string), nil)
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, int64(len("fixed")), api.ContentLength())
+	assert.Equal(t, ts.URL+"/fixed", api.FinalURL())
+}
+
+func TestStreamResponseToWriter(t *testing.T) {
+
+	payload := strings.Repeat("0123456789", 100000)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		io.WriteString(w, payload)
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL, MaxResponseBodySize: 1024}
+	hash := sha256.New()
+	api := NewBaseAPI(http.MethodGet, "/export", nil, hash, nil)
+	assert.Nil(t, client.Do(api))
+	expected := sha256.Sum256([]byte(payload))
+	assert.Equal(t, expected[:], hash.Sum(nil))
+	assert.Nil(t, api.RawResponse())
+	assert.Equal(t, int64(len(payload)), api.ContentLength())
+}
+
+func TestStreamResponseToReadCloser(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "", r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		io.WriteString(w, "streamed body")
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	var body io.ReadCloser
+	api := NewBaseAPI(http.MethodGet, "/", nil, &body, nil)
+	assert.Nil(t, client.Do(api))
+
+	data, err := ioutil.ReadAll(body)
+	assert.Nil(t, err)
+	assert.Nil(t, body.Close())
+	assert.Equal(t, "streamed body", string(data))
+	assert.Nil(t, api.RawResponse())
+}
+
+func TestStreamedErrorResponseIsDecoded(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error_id":"resource.not_found"}`))
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	var out bytes.Buffer
+	api := NewBaseAPI(http.MethodGet, "/", nil, &out, new(ErrStruct))
+	err := client.Do(api)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, 0, out.Len())
+	assert.Equal(t, "resource.not_found", api.ErrorObject().(*ErrStruct).ErrID)
+}
+
+func TestMaxResponseBodySize(t *testing.T) {
+
+	chunked := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, strings.Repeat("x", 100))
+		if chunked {
+			w.(http.Flusher).Flush()
+			io.WriteString(w, strings.Repeat("x", 100))
+		}
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL, MaxResponseBodySize: 150}
+	api := NewBaseAPI(http.MethodGet, "/", nil, new(string), nil)
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, 100, len(*api.ResponseObject().(*string)))
+
+	chunked = true
+	err := client.Do(NewBaseAPI(http.MethodGet, "/", nil, new(string), nil))
+	assert.True(t, errors.Is(err, ErrResponseTooLarge))
+	var tooLarge *ResponseTooLargeError
+	assert.True(t, errors.As(err, &tooLarge))
+	assert.Equal(t, int64(150), tooLarge.Limit)
+
+	chunked = false
+	client = Client{URL: ts.URL, MaxResponseBodySize: 50}
+	err = client.Do(NewBaseAPI(http.MethodGet, "/", nil, new(string), nil))
+	assert.True(t, errors.As(err, &tooLarge))
+	assert.Equal(t, int64(100), tooLarge.ContentLength)
+}
@@ -0,0 +1,369 @@
+package rest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/sky-uk/go-rest-api/contenttype"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNoCodec - Wrapped by the EncodeError returned when no codec is
+// registered for the request content type.
+var ErrNoCodec = errors.New("no codec registered for media type")
+
+// ErrUnsupportedObject - Returned (wrapped) by a Codec asked to marshal or
+// unmarshal a Go type it cannot handle.
+var ErrUnsupportedObject = errors.New("unsupported object type")
+
+// Codec - Encodes request objects and decodes response payloads of one or
+// more media types.
+type Codec interface {
+	// MediaTypes - Returns the "type/subtype" media types handled by the codec.
+	MediaTypes() []string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// TypedCodec - Optional interface of codecs that can only decode into some
+// Go types, e.g. *string. Codecs not implementing it are assumed to decode
+// into anything.
+type TypedCodec interface {
+	Codec
+	CanUnmarshal(v interface{}) bool
+}
+
+// canUnmarshal - Tells whether codec can decode into v.
+func canUnmarshal(codec Codec, v interface{}) bool {
+	if typed, ok := codec.(TypedCodec); ok {
+		return typed.CanUnmarshal(v)
+	}
+	return true
+}
+
+// CodecRegistry - Set of codecs looked up by media type. It is safe for
+// concurrent use.
+type CodecRegistry struct {
+	mu     sync.RWMutex
+	codecs map[string]Codec
+}
+
+// NewCodecRegistry - Returns a registry holding the given codecs.
+func NewCodecRegistry(codecs ...Codec) *CodecRegistry {
+	registry := &CodecRegistry{codecs: make(map[string]Codec)}
+	for _, codec := range codecs {
+		registry.Register(codec)
+	}
+	return registry
+}
+
+// Register - Adds codec to the registry, replacing any codec previously
+// registered for the same media types.
+func (r *CodecRegistry) Register(codec Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.codecs == nil {
+		r.codecs = make(map[string]Codec)
+	}
+	for _, mediaType := range codec.MediaTypes() {
+		r.codecs[strings.ToLower(mediaType)] = codec
+	}
+}
+
+// Lookup - Returns the codec for a Content-Type value, or nil when none is
+// registered. An exact "type/subtype" match is preferred, then a structured
+// syntax suffix match (application/problem+json is decoded as JSON), and
+// finally any codec registered for the same subtype, so that e.g. text/json
+// is decoded as JSON too.
+func (r *CodecRegistry) Lookup(contentType string) Codec {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if mediaType, err := contenttype.Parse(contentType); err == nil {
+		if codec, ok := r.codecs[mediaType.Essence()]; ok {
+			return codec
+		}
+		if mediaType.Suffix != "" {
+			if codec := r.lookupSubtype(mediaType.Suffix); codec != nil {
+				return codec
+			}
+		}
+	}
+	return r.lookupSubtype(contenttype.GetType(contentType))
+}
+
+// lookupSubtype - Returns the codec registered for a media type with the
+// given subtype, the first one in alphabetical order when there are several.
+func (r *CodecRegistry) lookupSubtype(subtype string) Codec {
+	if subtype == "" {
+		return nil
+	}
+	var found Codec
+	var foundType string
+	for mediaType, codec := range r.codecs {
+		if mediaType[strings.Index(mediaType, "/")+1:] == subtype && (found == nil || mediaType < foundType) {
+			found, foundType = codec, mediaType
+		}
+	}
+	return found
+}
+
+// byMediaType - Returns a snapshot of the registered codecs by media type.
+func (r *CodecRegistry) byMediaType() map[string]Codec {
+	codecs := make(map[string]Codec)
+	if r == nil {
+		return codecs
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for mediaType, codec := range r.codecs {
+		codecs[mediaType] = codec
+	}
+	return codecs
+}
+
+// DefaultCodecs - Registry consulted when a Client has no codec of its own
+// for a media type. It holds JSONCodec, XMLCodec, BytesCodec, TextCodec and
+// FormCodec.
+var DefaultCodecs = NewCodecRegistry(JSONCodec, XMLCodec, BytesCodec, TextCodec, FormCodec)
+
+// RegisterCodec - Adds codec to DefaultCodecs.
+func RegisterCodec(codec Codec) {
+	DefaultCodecs.Register(codec)
+}
+
+// codec - Returns the codec for contentType, looking at the client codecs
+// first and at DefaultCodecs next.
+func (restClient *Client) codec(contentType string) Codec {
+	if codec := restClient.Codecs.Lookup(contentType); codec != nil {
+		return codec
+	}
+	return DefaultCodecs.Lookup(contentType)
+}
+
+// accept - Returns the Accept header listing the media types whose codec can
+// decode into v. Media types of codecs written for the type of v (TypedCodec)
+// are preferred over the ones of generic codecs.
+func (restClient *Client) accept(v interface{}) contenttype.Accept {
+	codecs := DefaultCodecs.byMediaType()
+	for mediaType, codec := range restClient.Codecs.byMediaType() {
+		codecs[mediaType] = codec
+	}
+
+	var typed, generic []string
+	for mediaType, codec := range codecs {
+		if _, isTyped := codec.(TypedCodec); isTyped {
+			if canUnmarshal(codec, v) {
+				typed = append(typed, mediaType)
+			}
+		} else {
+			generic = append(generic, mediaType)
+		}
+	}
+	sort.Strings(typed)
+	sort.Strings(generic)
+
+	mediaRanges := typed
+	for _, mediaType := range generic {
+		if len(typed) > 0 {
+			mediaType += ";q=0.5"
+		}
+		mediaRanges = append(mediaRanges, mediaType)
+	}
+	accept, err := contenttype.NewAccept(mediaRanges...)
+	if err != nil {
+		return nil
+	}
+	return accept
+}
+
+// checkAcceptable - Makes sure a successful response of the given content
+// type can be decoded into target and, when the caller set the Accept header
+// itself, that the content type is one it accepts.
+func (restClient *Client) checkAcceptable(contentType string, target interface{}, explicitAccept string) error {
+	if target == nil || contentType == "" {
+		return nil
+	}
+	codec := restClient.codec(contentType)
+	acceptable := codec != nil && canUnmarshal(codec, target)
+	if acceptable && explicitAccept != "" {
+		accept, acceptErr := contenttype.ParseAccept(explicitAccept)
+		mediaType, err := contenttype.Parse(contentType)
+		acceptable = acceptErr != nil || err != nil || accept.Accepts(mediaType)
+	}
+	if !acceptable {
+		accept := explicitAccept
+		if accept == "" {
+			accept = restClient.accept(target).String()
+		}
+		return &NotAcceptableError{ContentType: contentType, Accept: accept}
+	}
+	return nil
+}
+
+// JSONCodec - Codec for application/json using encoding/json.
+var JSONCodec Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) MediaTypes() []string                       { return []string{"application/json"} }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// XMLCodec - Codec for application/xml and text/xml using encoding/xml.
+var XMLCodec Codec = xmlCodec{}
+
+type xmlCodec struct{}
+
+func (xmlCodec) MediaTypes() []string                       { return []string{"application/xml", "text/xml"} }
+func (xmlCodec) Marshal(v interface{}) ([]byte, error)      { return xml.Marshal(v) }
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error { return xml.Unmarshal(data, v) }
+
+// BytesCodec - Codec for application/octet-stream, sending a []byte or string
+// request object as is and storing the response payload in a *[]byte.
+var BytesCodec Codec = bytesCodec{}
+
+type bytesCodec struct{}
+
+func (bytesCodec) MediaTypes() []string { return []string{"application/octet-stream"} }
+
+func (bytesCodec) CanUnmarshal(v interface{}) bool {
+	_, is := v.(*[]byte)
+	return is
+}
+
+func (bytesCodec) Marshal(v interface{}) ([]byte, error) {
+	return rawPayload(v)
+}
+
+func (bytesCodec) Unmarshal(data []byte, v interface{}) error {
+	if pstream, is := v.(*[]byte); is {
+		*pstream = data
+		return nil
+	}
+	return fmt.Errorf("%w: response object expected to be *[]byte, got %T", ErrUnsupportedObject, v)
+}
+
+// TextCodec - Codec for text/plain and text/html, sending a []byte or string
+// request object as is and storing the response payload in a *string.
+var TextCodec Codec = textCodec{}
+
+type textCodec struct{}
+
+func (textCodec) MediaTypes() []string { return []string{"text/plain", "text/html"} }
+
+func (textCodec) CanUnmarshal(v interface{}) bool {
+	switch v.(type) {
+	case *string, *[]byte:
+		return true
+	}
+	return false
+}
+
+func (textCodec) Marshal(v interface{}) ([]byte, error) {
+	return rawPayload(v)
+}
+
+func (textCodec) Unmarshal(data []byte, v interface{}) error {
+	switch pstream := v.(type) {
+	case *string:
+		*pstream = string(data)
+	case *[]byte:
+		*pstream = data
+	default:
+		return fmt.Errorf("%w: response object expected to be *string, got %T", ErrUnsupportedObject, v)
+	}
+	return nil
+}
+
+// FormCodec - Codec for application/x-www-form-urlencoded. It encodes
+// url.Values, map[string]string, map[string][]string and structs annotated
+// with url tags (see EncodeQuery), and decodes into pointers to the same.
+// Structs are only advertised in the Accept header when they declare url
+// tags.
+var FormCodec Codec = formCodec{}
+
+type formCodec struct{}
+
+func (formCodec) MediaTypes() []string { return []string{"application/x-www-form-urlencoded"} }
+
+func (formCodec) CanUnmarshal(v interface{}) bool {
+	switch v.(type) {
+	case *url.Values, *map[string]string, *map[string][]string:
+		return true
+	}
+	t := reflect.TypeOf(v)
+	return t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct && hasURLTags(t)
+}
+
+func (formCodec) Marshal(v interface{}) ([]byte, error) {
+	switch payload := v.(type) {
+	case url.Values:
+		return []byte(payload.Encode()), nil
+	case map[string][]string:
+		return []byte(url.Values(payload).Encode()), nil
+	case map[string]string:
+		values := make(url.Values, len(payload))
+		for key, value := range payload {
+			values.Set(key, value)
+		}
+		return []byte(values.Encode()), nil
+	case []byte, string:
+		return rawPayload(v)
+	}
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: request object of type %T cannot be form encoded", ErrUnsupportedObject, v)
+	}
+	values, err := EncodeQuery(v)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(values.Encode()), nil
+}
+
+func (formCodec) Unmarshal(data []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	switch target := v.(type) {
+	case *url.Values:
+		*target = values
+	case *map[string][]string:
+		*target = values
+	case *map[string]string:
+		*target = make(map[string]string, len(values))
+		for key := range values {
+			(*target)[key] = values.Get(key)
+		}
+	default:
+		t := reflect.TypeOf(v)
+		if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("%w: response object expected to be a pointer to url.Values, a map or a struct, got %T", ErrUnsupportedObject, v)
+		}
+		return DecodeQuery(values, v)
+	}
+	return nil
+}
+
+func rawPayload(v interface{}) ([]byte, error) {
+	switch payload := v.(type) {
+	case []byte:
+		return payload, nil
+	case string:
+		return []byte(payload), nil
+	}
+	return nil, fmt.Errorf("%w: request object of type %T is neither []byte nor string", ErrUnsupportedObject, v)
+}
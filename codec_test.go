@@ -0,0 +1,259 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// csvCodec - Test codec encoding a []string as one comma separated line.
+type csvCodec struct{}
+
+func (csvCodec) MediaTypes() []string { return []string{"text/csv"} }
+
+func (csvCodec) Marshal(v interface{}) ([]byte, error) {
+	fields, ok := v.([]string)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedObject, v)
+	}
+	return []byte(strings.Join(fields, ",")), nil
+}
+
+func (csvCodec) Unmarshal(data []byte, v interface{}) error {
+	fields, ok := v.(*[]string)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrUnsupportedObject, v)
+	}
+	*fields = strings.Split(strings.TrimSpace(string(data)), ",")
+	return nil
+}
+
+func TestCodecRegistryLookup(t *testing.T) {
+	registry := NewCodecRegistry(JSONCodec, XMLCodec, TextCodec)
+
+	assert.Equal(t, JSONCodec, registry.Lookup("application/json"))
+	assert.Equal(t, JSONCodec, registry.Lookup("Application/JSON; charset=utf-8"))
+	assert.Equal(t, JSONCodec, registry.Lookup("text/json"))
+	assert.Equal(t, XMLCodec, registry.Lookup("text/xml"))
+	assert.Equal(t, TextCodec, registry.Lookup("application/plain"))
+	assert.Nil(t, registry.Lookup("foo/bar"))
+	assert.Nil(t, registry.Lookup(""))
+
+	var empty *CodecRegistry
+	assert.Nil(t, empty.Lookup("application/json"))
+}
+
+func TestClientCodec(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, "a,b,c", string(body))
+		assert.Equal(t, "text/csv", r.Header.Get("Content-Type"))
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Write([]byte("x,y\n"))
+	}))
+	defer ts.Close()
+
+	client := Client{
+		URL:     ts.URL,
+		Headers: map[string]string{"Content-Type": "text/csv"},
+		Codecs:  NewCodecRegistry(csvCodec{}),
+	}
+	out := new([]string)
+	assert.Nil(t, client.Do(NewBaseAPI(http.MethodPost, "/", []string{"a", "b", "c"}, out, nil)))
+	assert.Equal(t, []string{"x", "y"}, *out)
+
+	client = Client{URL: ts.URL, Headers: map[string]string{"Content-Type": "text/csv"}}
+	err := client.Do(NewBaseAPI(http.MethodPost, "/", []string{"a", "b", "c"}, out, nil))
+	var encodeErr *EncodeError
+	assert.True(t, errors.As(err, &encodeErr))
+	assert.True(t, errors.Is(err, ErrNoCodec))
+}
+
+func TestRegisterCodec(t *testing.T) {
+	assert.Nil(t, DefaultCodecs.Lookup("text/csv"))
+	RegisterCodec(csvCodec{})
+	defer func() {
+		DefaultCodecs.mu.Lock()
+		delete(DefaultCodecs.codecs, "text/csv")
+		DefaultCodecs.mu.Unlock()
+	}()
+
+	client := Client{}
+	assert.NotNil(t, client.codec("text/csv"))
+}
+
+func TestDecodeError(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"fields":`))
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	err := client.Do(NewBaseAPI(http.MethodGet, "/", nil, new(JSONFoo), nil))
+	var decodeErr *DecodeError
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, "application/json", decodeErr.ContentType)
+}
+
+func TestCodecLookupBySuffix(t *testing.T) {
+	registry := NewCodecRegistry(JSONCodec, XMLCodec, TextCodec)
+
+	assert.Equal(t, JSONCodec, registry.Lookup("application/problem+json"))
+	assert.Equal(t, JSONCodec, registry.Lookup(`application/vnd.api+json; ext="https://jsonapi.org/ext/atomic"`))
+	assert.Equal(t, JSONCodec, registry.Lookup("application/hal+json; charset=utf-8"))
+	assert.Equal(t, XMLCodec, registry.Lookup("application/atom+xml"))
+	assert.Nil(t, registry.Lookup("application/vnd.custom+cbor"))
+
+	registry.Register(csvCodec{})
+	assert.Equal(t, csvCodec{}, registry.Lookup("text/csv; header=present"))
+}
+
+func TestProblemJSONResponse(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error_id":"conflict"}`))
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	api := NewBaseAPI(http.MethodGet, "/", nil, nil, new(ErrStruct))
+	err := client.Do(api)
+	assert.True(t, errors.Is(err, ErrConflict))
+	assert.Equal(t, "conflict", api.ErrorObject().(*ErrStruct).ErrID)
+}
+
+func TestAcceptDerivedFromCodecs(t *testing.T) {
+	client := Client{}
+	assert.Equal(t, "application/json, application/xml, text/xml", client.accept(new(JSONFoo)).String())
+	assert.Equal(t, "text/html, text/plain, application/json;q=0.5, application/xml;q=0.5, text/xml;q=0.5",
+		client.accept(new(string)).String())
+	assert.Equal(t, "application/octet-stream, text/html, text/plain, application/json;q=0.5, application/xml;q=0.5, text/xml;q=0.5",
+		client.accept(new([]byte)).String())
+
+	client = Client{Codecs: NewCodecRegistry(csvCodec{})}
+	assert.Equal(t, "application/json, application/xml, text/csv, text/xml", client.accept(new([]string)).String())
+}
+
+func TestAcceptHeaderSent(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(r.Header.Get("Accept")))
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	api := NewBaseAPI(http.MethodGet, "/", nil, nil, nil)
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, "", string(api.RawResponse()))
+
+	out := new(string)
+	assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/", nil, out, nil)))
+	assert.Equal(t, "text/html, text/plain, application/json;q=0.5, application/xml;q=0.5, text/xml;q=0.5", *out)
+
+	client = Client{URL: ts.URL, Headers: map[string]string{"Accept": "text/*"}}
+	assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/", nil, out, nil)))
+	assert.Equal(t, "text/*", *out)
+}
+
+func TestNotAcceptableResponse(t *testing.T) {
+
+	contentType := "text/html"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(`{"fields":{"foo":"bar"}}`))
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	api := NewBaseAPI(http.MethodGet, "/", nil, new(JSONFoo), nil)
+	err := client.Do(api)
+	assert.True(t, errors.Is(err, ErrNotAcceptable))
+	var notAcceptable *NotAcceptableError
+	assert.True(t, errors.As(err, &notAcceptable))
+	assert.Equal(t, "text/html", notAcceptable.ContentType)
+	assert.Equal(t, "application/json, application/xml, text/xml", notAcceptable.Accept)
+	assert.Equal(t, http.StatusOK, api.StatusCode())
+
+	contentType = "image/png"
+	err = client.Do(NewBaseAPI(http.MethodGet, "/", nil, new(JSONFoo), nil))
+	assert.True(t, errors.Is(err, ErrNotAcceptable))
+
+	contentType = "application/xml"
+	client = Client{URL: ts.URL, Headers: map[string]string{"Accept": "application/json"}}
+	err = client.Do(NewBaseAPI(http.MethodGet, "/", nil, new(JSONFoo), nil))
+	assert.True(t, errors.As(err, &notAcceptable))
+	assert.Equal(t, "application/json", notAcceptable.Accept)
+
+	contentType = "application/json"
+	api = NewBaseAPI(http.MethodGet, "/", nil, new(JSONFoo), nil)
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, "bar", api.ResponseObject().(*JSONFoo).Fields["foo"])
+}
+
+type Credentials struct {
+	GrantType string   `url:"grant_type"`
+	Scope     []string `url:"scope,comma,omitempty"`
+}
+
+func TestFormCodec(t *testing.T) {
+	data, err := FormCodec.Marshal(url.Values{"b": {"2"}, "a": {"1", "x y"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "a=1&a=x+y&b=2", string(data))
+	data, err = FormCodec.Marshal(map[string]string{"user": "nsxUser"})
+	assert.Nil(t, err)
+	assert.Equal(t, "user=nsxUser", string(data))
+	data, err = FormCodec.Marshal(&Credentials{GrantType: "client_credentials", Scope: []string{"read", "write"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "grant_type=client_credentials&scope=read%2Cwrite", string(data))
+	_, err = FormCodec.Marshal(42)
+	assert.True(t, errors.Is(err, ErrUnsupportedObject))
+
+	payload := []byte("grant_type=password&scope=read%2Cwrite")
+	values := url.Values{}
+	assert.Nil(t, FormCodec.Unmarshal(payload, &values))
+	assert.Equal(t, "password", values.Get("grant_type"))
+	fields := map[string]string{}
+	assert.Nil(t, FormCodec.Unmarshal(payload, &fields))
+	assert.Equal(t, map[string]string{"grant_type": "password", "scope": "read,write"}, fields)
+	credentials := new(Credentials)
+	assert.Nil(t, FormCodec.Unmarshal(payload, credentials))
+	assert.Equal(t, &Credentials{GrantType: "password", Scope: []string{"read", "write"}}, credentials)
+	assert.True(t, errors.Is(FormCodec.Unmarshal(payload, new(string)), ErrUnsupportedObject))
+
+	typed := FormCodec.(TypedCodec)
+	assert.True(t, typed.CanUnmarshal(&values))
+	assert.True(t, typed.CanUnmarshal(credentials))
+	assert.False(t, typed.CanUnmarshal(new(JSONFoo)))
+}
+
+func TestFormRequestAndResponse(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-www-form-urlencoded", r.Header.Get("Content-Type"))
+		assert.Equal(t, "application/x-www-form-urlencoded, application/json;q=0.5, application/xml;q=0.5, text/xml;q=0.5",
+			r.Header.Get("Accept"))
+		assert.Nil(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+		w.Write([]byte("grant_type=" + r.PostForm.Get("grant_type") + "&scope=read"))
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	out := new(Credentials)
+	api := NewBaseAPI(http.MethodPost, "/token", &Credentials{GrantType: "client_credentials"}, out, nil)
+	api.SetContentType("application/x-www-form-urlencoded")
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, &Credentials{GrantType: "client_credentials", Scope: []string{"read"}}, out)
+}
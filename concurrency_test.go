@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentCallsOnSharedClient(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_id":"resource.not_found"}`))
+			return
+		}
+		fmt.Fprintf(w, `{"field_1":%q}`, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	headers := map[string]string{"Content-Type": "application/json"}
+	client := &Client{URL: ts.URL, User: user, Password: password, Headers: headers}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				path := fmt.Sprintf("/item-%d-%d", i, j)
+				method := http.MethodGet
+				if j%2 == 0 {
+					method = http.MethodPut
+				}
+				api := NewBaseAPI(method, path, ReqBody{FieldOne: "Foo"}, new(ReqBody), new(ErrStruct))
+				if j%5 == 0 {
+					api = NewBaseAPI(method, "/missing", ReqBody{}, new(ReqBody), new(ErrStruct))
+				}
+
+				err := client.Do(api)
+				if j%5 == 0 {
+					assert.NotNil(t, err)
+					assert.Equal(t, http.StatusNotFound, api.StatusCode())
+					assert.Equal(t, "resource.not_found", api.ErrorObject().(*ErrStruct).ErrID)
+					continue
+				}
+				assert.Nil(t, err)
+				assert.Equal(t, http.StatusOK, api.StatusCode())
+				assert.Equal(t, path, api.ResponseObject().(*ReqBody).FieldOne)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string]string{"Content-Type": "application/json"}, client.Headers)
+}
+
+func TestConcurrentCallsDoNotSetDefaultHeaders(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, defaultContentType, r.Header.Get("Content-Type"))
+	}))
+	defer ts.Close()
+
+	client := &Client{URL: ts.URL}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/", nil, nil, nil)))
+		}()
+	}
+	wg.Wait()
+
+	assert.Nil(t, client.Headers)
+}
@@ -0,0 +1,140 @@
+package contenttype
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AcceptRange - One element of an Accept header: a media range, possibly
+// with wildcards, and its quality value.
+type AcceptRange struct {
+	MediaType
+	Q float64
+}
+
+// Accept - A parsed Accept header, ordered from the most to the least
+// preferred range. An empty Accept accepts everything.
+type Accept []AcceptRange
+
+// ParseAccept - Parses an Accept header value such as
+// "application/json, application/xml;q=0.9, */*;q=0.1".
+func ParseAccept(s string) (Accept, error) {
+	var accept Accept
+	p := &parser{s: s}
+	for {
+		p.skipOWS()
+		if p.done() {
+			break
+		}
+		if p.consume(',') {
+			continue
+		}
+		mediaRange, err := p.mediaType()
+		if err != nil {
+			return nil, err
+		}
+		if mediaRange.Type == "*" && mediaRange.Subtype != "*" {
+			return nil, p.errorf("invalid media range %s", mediaRange.Essence())
+		}
+		acceptRange := AcceptRange{MediaType: mediaRange, Q: 1}
+		if q, ok := mediaRange.Params["q"]; ok {
+			if acceptRange.Q, err = parseQuality(q); err != nil {
+				return nil, p.errorf("invalid quality value %q", q)
+			}
+			delete(mediaRange.Params, "q")
+		}
+		accept = append(accept, acceptRange)
+
+		p.skipOWS()
+		if !p.done() && !p.consume(',') {
+			return nil, p.errorf("unexpected %q", p.s[p.pos])
+		}
+	}
+	accept.sort()
+	return accept, nil
+}
+
+// NewAccept - Builds an Accept header from media types given in order of
+// preference, each one optionally followed by ";q=<value>".
+func NewAccept(mediaRanges ...string) (Accept, error) {
+	return ParseAccept(strings.Join(mediaRanges, ", "))
+}
+
+// parseQuality - Parses a weight: "0" to "1" with up to three decimals.
+func parseQuality(s string) (float64, error) {
+	q, err := strconv.ParseFloat(s, 64)
+	if err != nil || q < 0 || q > 1 || len(s) > 5 {
+		return 0, ErrInvalidMediaType
+	}
+	return q, nil
+}
+
+// sort - Orders the ranges by decreasing quality, then by decreasing
+// specificity, keeping the original order otherwise.
+func (a Accept) sort() {
+	sort.SliceStable(a, func(i, j int) bool {
+		if a[i].Q != a[j].Q {
+			return a[i].Q > a[j].Q
+		}
+		return specificity(a[i].MediaType) > specificity(a[j].MediaType)
+	})
+}
+
+// specificity - Ranks media ranges: */* < type/* < type/subtype <
+// type/subtype;params.
+func specificity(m MediaType) int {
+	switch {
+	case m.Type == "*":
+		return 0
+	case m.Subtype == "*":
+		return 1
+	}
+	return 2 + len(m.Params)
+}
+
+// String - Formats the Accept header.
+func (a Accept) String() string {
+	elements := make([]string, len(a))
+	for i, acceptRange := range a {
+		elements[i] = acceptRange.MediaType.String()
+		if acceptRange.Q < 1 {
+			elements[i] += ";q=" + strconv.FormatFloat(acceptRange.Q, 'f', -1, 64)
+		}
+	}
+	return strings.Join(elements, ", ")
+}
+
+// Quality - Returns the quality value given to m: the one of the most
+// specific range matching m, 0 when no range matches. An empty Accept gives
+// 1 to every media type.
+func (a Accept) Quality(m MediaType) float64 {
+	if len(a) == 0 {
+		return 1
+	}
+	best, q := -1, 0.0
+	for _, acceptRange := range a {
+		if s := specificity(acceptRange.MediaType); s > best && m.Match(acceptRange.MediaType) {
+			best, q = s, acceptRange.Q
+		}
+	}
+	return q
+}
+
+// Accepts - Reports whether m is acceptable, i.e. has a non-zero quality.
+func (a Accept) Accepts(m MediaType) bool {
+	return a.Quality(m) > 0
+}
+
+// Negotiate - Returns the offer with the highest quality, the earliest one
+// on ties, and false when none is acceptable.
+func (a Accept) Negotiate(offers ...MediaType) (MediaType, bool) {
+	var best MediaType
+	bestQ := 0.0
+	for _, offer := range offers {
+		if q := a.Quality(offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best, bestQ > 0
+}
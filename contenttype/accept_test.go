@@ -0,0 +1,58 @@
+package contenttype
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseAccept(t *testing.T) {
+	accept, err := ParseAccept("text/*;q=0.5, */*; q=0.1, application/json, application/xml;q=0.9, text/html;level=1")
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(accept))
+	assert.Equal(t, "text/html;level=1", accept[0].Essence()+";level="+accept[0].Param("level"))
+	assert.Equal(t, "application/json", accept[1].Essence())
+	assert.Equal(t, 0.9, accept[2].Q)
+	assert.Equal(t, "text/*", accept[3].Essence())
+	assert.Equal(t, "*/*", accept[4].Essence())
+	assert.Equal(t, "text/html; level=1, application/json, application/xml;q=0.9, text/*;q=0.5, */*;q=0.1", accept.String())
+
+	accept, err = ParseAccept("")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(accept))
+
+	for _, value := range []string{"*/json", "text/plain;q=2", "text/plain;q=0.12345", "text/plain;q=x", "text/plain text/html"} {
+		_, err := ParseAccept(value)
+		assert.True(t, errors.Is(err, ErrInvalidMediaType), value)
+	}
+}
+
+func TestAcceptQuality(t *testing.T) {
+	accept, err := NewAccept("text/*;q=0.3", "text/html;q=0.7", "text/html;level=1", "text/html;level=2;q=0.4", "*/*;q=0.5")
+	assert.Nil(t, err)
+
+	// RFC 9110 section 12.5.1 example.
+	assert.Equal(t, 1.0, accept.Quality(MustParse("text/html;level=1")))
+	assert.Equal(t, 0.7, accept.Quality(MustParse("text/html")))
+	assert.Equal(t, 0.3, accept.Quality(MustParse("text/plain")))
+	assert.Equal(t, 0.5, accept.Quality(MustParse("image/jpeg")))
+	assert.Equal(t, 0.4, accept.Quality(MustParse("text/html;level=2")))
+	assert.Equal(t, 0.7, accept.Quality(MustParse("text/html;level=3")))
+
+	accept, _ = NewAccept("application/json", "application/xml;q=0")
+	assert.True(t, accept.Accepts(MustParse("application/json; charset=utf-8")))
+	assert.False(t, accept.Accepts(MustParse("application/xml")))
+	assert.False(t, accept.Accepts(MustParse("text/plain")))
+	assert.True(t, Accept{}.Accepts(MustParse("text/plain")))
+}
+
+func TestNegotiate(t *testing.T) {
+	accept, _ := NewAccept("application/xml;q=0.8", "application/json")
+
+	best, ok := accept.Negotiate(MustParse("application/xml"), MustParse("application/json"))
+	assert.True(t, ok)
+	assert.Equal(t, "application/json", best.Essence())
+
+	_, ok = accept.Negotiate(MustParse("text/plain"))
+	assert.False(t, ok)
+}
@@ -8,6 +8,7 @@ import (
 // the type found
 // A valid Content-Type string matches this BNF:
 // Content-Type := type "/" subtype *[";" parameter]
+// Use Parse to get the type, suffix and parameters as well.
 func GetType(content string) string {
 	subs := strings.Split(content, ";")
 	types := strings.Split(subs[0], "/")
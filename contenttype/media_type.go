@@ -0,0 +1,272 @@
+package contenttype
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidMediaType - Wrapped by the errors returned by Parse.
+var ErrInvalidMediaType = errors.New("invalid media type")
+
+// MediaType - A parsed media type as defined by RFC 6838 and RFC 9110:
+//
+//	media-type = type "/" subtype *( OWS ";" OWS [ parameter ] )
+//	parameter  = parameter-name "=" ( token / quoted-string )
+//
+// Type, Subtype, Suffix and parameter names are lowercased, as they are case
+// insensitive; parameter values are kept as sent, unquoted.
+type MediaType struct {
+	Type    string
+	Subtype string
+	// Suffix - The structured syntax suffix of the subtype, without the
+	// "+", e.g. "json" for application/problem+json.
+	Suffix string
+	Params map[string]string
+}
+
+// Parse - Parses a Content-Type (or Accept element) value such as
+// `application/vnd.api+json; charset="utf-8"`.
+func Parse(s string) (MediaType, error) {
+	p := &parser{s: s}
+	mediaType, err := p.mediaType()
+	if err != nil {
+		return MediaType{}, err
+	}
+	p.skipOWS()
+	if !p.done() {
+		return MediaType{}, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	return mediaType, nil
+}
+
+// MustParse - Like Parse but panics on error; for media type constants.
+func MustParse(s string) MediaType {
+	mediaType, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return mediaType
+}
+
+// Essence - Returns "type/subtype", without parameters.
+func (m MediaType) Essence() string {
+	return m.Type + "/" + m.Subtype
+}
+
+// Syntax - Returns the structured syntax of the media type: the suffix when
+// there is one, the subtype otherwise. Both application/json and
+// application/hal+json give "json".
+func (m MediaType) Syntax() string {
+	if m.Suffix != "" {
+		return m.Suffix
+	}
+	return m.Subtype
+}
+
+// Param - Returns the value of the named parameter, "" when absent.
+func (m MediaType) Param(name string) string {
+	return m.Params[strings.ToLower(name)]
+}
+
+// String - Formats the media type, quoting parameter values when needed.
+// Parameters are sorted by name.
+func (m MediaType) String() string {
+	var b strings.Builder
+	b.WriteString(m.Essence())
+	names := make([]string, 0, len(m.Params))
+	for name := range m.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString("; ")
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(quote(m.Params[name]))
+	}
+	return b.String()
+}
+
+// Match - Reports whether m is matched by pattern, which may use "*" as type
+// and/or subtype (e.g. "*/*", "application/*"). Every parameter of pattern
+// must be present in m with the same value, charset being compared case
+// insensitively.
+func (m MediaType) Match(pattern MediaType) bool {
+	if pattern.Type != "*" && pattern.Type != m.Type {
+		return false
+	}
+	if pattern.Subtype != "*" && pattern.Subtype != m.Subtype {
+		return false
+	}
+	for name, value := range pattern.Params {
+		actual, ok := m.Params[name]
+		if !ok {
+			return false
+		}
+		if name == "charset" {
+			if !strings.EqualFold(actual, value) {
+				return false
+			}
+		} else if actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// HasSuffix - Reports whether the subtype carries the given structured
+// syntax suffix, e.g. HasSuffix("json").
+func (m MediaType) HasSuffix(suffix string) bool {
+	return m.Suffix == strings.ToLower(strings.TrimPrefix(suffix, "+"))
+}
+
+// parser - Recursive descent parser over the RFC 9110 grammar.
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w %q: %s", ErrInvalidMediaType, p.s, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipOWS() {
+	for !p.done() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) consume(c byte) bool {
+	if !p.done() && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) token() string {
+	start := p.pos
+	for !p.done() && isTokenChar(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *parser) quotedString() (string, error) {
+	var b strings.Builder
+	p.pos++ // opening DQUOTE
+	for !p.done() {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == '"':
+			return b.String(), nil
+		case c == '\\':
+			if p.done() {
+				return "", p.errorf("unterminated quoted-pair")
+			}
+			b.WriteByte(p.s[p.pos])
+			p.pos++
+		case c == '\t' || (c >= 0x20 && c != 0x7f):
+			b.WriteByte(c)
+		default:
+			return "", p.errorf("invalid character %q in quoted-string", c)
+		}
+	}
+	return "", p.errorf("unterminated quoted-string")
+}
+
+func (p *parser) mediaType() (MediaType, error) {
+	p.skipOWS()
+	mediaType := MediaType{Type: strings.ToLower(p.token())}
+	if mediaType.Type == "" {
+		return MediaType{}, p.errorf("missing type")
+	}
+	if !p.consume('/') {
+		return MediaType{}, p.errorf("missing subtype")
+	}
+	mediaType.Subtype = strings.ToLower(p.token())
+	if mediaType.Subtype == "" {
+		return MediaType{}, p.errorf("missing subtype")
+	}
+	if i := strings.LastIndexByte(mediaType.Subtype, '+'); i >= 0 {
+		mediaType.Suffix = mediaType.Subtype[i+1:]
+	}
+
+	params, err := p.params()
+	if err != nil {
+		return MediaType{}, err
+	}
+	mediaType.Params = params
+	return mediaType, nil
+}
+
+// params - Parses *( OWS ";" OWS [ parameter ] ), stopping at anything else.
+func (p *parser) params() (map[string]string, error) {
+	params := make(map[string]string)
+	for {
+		p.skipOWS()
+		if !p.consume(';') {
+			return params, nil
+		}
+		p.skipOWS()
+		if p.done() || p.s[p.pos] == ';' || p.s[p.pos] == ',' {
+			continue
+		}
+		name := strings.ToLower(p.token())
+		if name == "" {
+			return nil, p.errorf("invalid parameter name")
+		}
+		if !p.consume('=') {
+			return nil, p.errorf("missing value for parameter %s", name)
+		}
+		var value string
+		if !p.done() && p.s[p.pos] == '"' {
+			var err error
+			if value, err = p.quotedString(); err != nil {
+				return nil, err
+			}
+		} else if value = p.token(); value == "" {
+			return nil, p.errorf("missing value for parameter %s", name)
+		}
+		if _, duplicate := params[name]; duplicate {
+			return nil, p.errorf("duplicate parameter %s", name)
+		}
+		params[name] = value
+	}
+}
+
+// isTokenChar - tchar as defined by RFC 9110.
+func isTokenChar(c byte) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+// quote - Returns value as a token when possible, as a quoted-string otherwise.
+func quote(value string) string {
+	isToken := value != ""
+	for i := 0; i < len(value) && isToken; i++ {
+		isToken = isTokenChar(value[i])
+	}
+	if isToken {
+		return value
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		if value[i] == '"' || value[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(value[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package contenttype
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	mediaType, err := Parse(`Application/Vnd.API+JSON; Charset="utf-8" ;profile="a \"b\" c"`)
+	assert.Nil(t, err)
+	assert.Equal(t, "application", mediaType.Type)
+	assert.Equal(t, "vnd.api+json", mediaType.Subtype)
+	assert.Equal(t, "json", mediaType.Suffix)
+	assert.Equal(t, "json", mediaType.Syntax())
+	assert.Equal(t, "application/vnd.api+json", mediaType.Essence())
+	assert.Equal(t, "utf-8", mediaType.Param("charset"))
+	assert.Equal(t, `a "b" c`, mediaType.Param("Profile"))
+	assert.Equal(t, `application/vnd.api+json; charset=utf-8; profile="a \"b\" c"`, mediaType.String())
+
+	mediaType, err = Parse("text/plain")
+	assert.Nil(t, err)
+	assert.Equal(t, "", mediaType.Suffix)
+	assert.Equal(t, "plain", mediaType.Syntax())
+	assert.Equal(t, 0, len(mediaType.Params))
+
+	mediaType, err = Parse("application/problem+xml;")
+	assert.Nil(t, err)
+	assert.True(t, mediaType.HasSuffix("+xml"))
+
+	mediaType, err = Parse(`multipart/form-data; boundary="simple boundary"`)
+	assert.Nil(t, err)
+	assert.Equal(t, "simple boundary", mediaType.Param("boundary"))
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"text",
+		"text/",
+		"/plain",
+		"text/plain; charset",
+		"text/plain; charset=",
+		`text/plain; charset="utf-8`,
+		"text/plain; charset=utf-8; charset=ascii",
+		"text/plain extra",
+		"Content-Type: text/html",
+	} {
+		_, err := Parse(value)
+		assert.True(t, errors.Is(err, ErrInvalidMediaType), value)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	json := MustParse("application/json; charset=UTF-8")
+	assert.True(t, json.Match(MustParse("*/*")))
+	assert.True(t, json.Match(MustParse("application/*")))
+	assert.True(t, json.Match(MustParse("application/json")))
+	assert.True(t, json.Match(MustParse("application/json; charset=utf-8")))
+	assert.False(t, json.Match(MustParse("application/json; charset=latin1")))
+	assert.False(t, json.Match(MustParse("application/json; version=2")))
+	assert.False(t, json.Match(MustParse("text/*")))
+	assert.False(t, json.Match(MustParse("application/xml")))
+}
@@ -0,0 +1,289 @@
+package rest
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DigestAuth - Authenticator answering the HTTP Digest challenges of RFC
+// 7616, with the MD5 and SHA-256 algorithms (and their -sess variants) and
+// qop=auth. The first request of a server is answered with a challenge, and
+// sent again with the digest of the credentials; later requests reuse the
+// challenge, counting the uses of its nonce, until the server asks for a
+// new one.
+type DigestAuth struct {
+	User     string
+	Password string
+
+	mu        sync.Mutex
+	challenge *digestChallenge
+	nc        uint32
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	stale     bool
+}
+
+// digestAlgorithms - Supported algorithms, from the most preferred one.
+var digestAlgorithms = []string{"SHA-256", "SHA-256-SESS", "MD5", "MD5-SESS"}
+
+// Authenticate - Sets the Authorization header once a challenge has been
+// received; requests are sent without it until then.
+func (a *DigestAuth) Authenticate(req *http.Request) error {
+	if a.User == "" {
+		return ErrMissingCredentials
+	}
+	a.mu.Lock()
+	challenge := a.challenge
+	if challenge == nil {
+		a.mu.Unlock()
+		return nil
+	}
+	a.nc++
+	nc := a.nc
+	a.mu.Unlock()
+
+	cnonce, err := newCnonce()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", challenge.authorization(a.User, a.Password, req.Method, req.URL.RequestURI(), nc, cnonce))
+	return nil
+}
+
+// Reauthenticate - Takes the challenge of a 401 Unauthorized response, and
+// the next nonce of an Authentication-Info header. The request is sent
+// again unless it was already answering the same nonce, which then means
+// the credentials are wrong.
+func (a *DigestAuth) Reauthenticate(req *http.Request, res *http.Response) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.challenge != nil {
+		info := parseAuthParams(res.Header.Get("Authentication-Info"))
+		if nextNonce := info["nextnonce"]; nextNonce != "" && nextNonce != a.challenge.nonce {
+			next := *a.challenge
+			next.nonce = nextNonce
+			a.challenge = &next
+			a.nc = 0
+		}
+	}
+	if res.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	challenge := selectDigestChallenge(parseChallenges(res.Header.Values("Www-Authenticate")))
+	if challenge == nil {
+		return false
+	}
+	sent := parseChallenges(req.Header.Values("Authorization"))
+	if !challenge.stale && len(sent) == 1 && strings.EqualFold(sent[0].scheme, "Digest") &&
+		sent[0].params["nonce"] == challenge.nonce {
+		return false
+	}
+	a.challenge = challenge
+	a.nc = 0
+	return true
+}
+
+// authorization - Returns the Authorization header answering the challenge.
+func (c *digestChallenge) authorization(user, password, method, uri string, nc uint32, cnonce string) string {
+	newHash := md5.New
+	if strings.HasPrefix(c.algorithm, "SHA-256") {
+		newHash = sha256.New
+	}
+	h := func(s string) string {
+		digest := newHash()
+		digest.Write([]byte(s))
+		return hex.EncodeToString(digest.Sum(nil))
+	}
+	ncValue := fmt.Sprintf("%08x", nc)
+
+	ha1 := h(user + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(c.algorithm, "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+	response := h(ha1 + ":" + c.nonce + ":" + ha2)
+	if c.qop != "" {
+		response = h(ha1 + ":" + c.nonce + ":" + ncValue + ":" + cnonce + ":" + c.qop + ":" + ha2)
+	}
+
+	fields := []string{
+		fmt.Sprintf(`username="%s"`, quoteEscaper.Replace(user)),
+		fmt.Sprintf(`realm="%s"`, quoteEscaper.Replace(c.realm)),
+		fmt.Sprintf(`uri="%s"`, quoteEscaper.Replace(uri)),
+		"algorithm=" + displayAlgorithm(c.algorithm),
+		fmt.Sprintf(`nonce="%s"`, quoteEscaper.Replace(c.nonce)),
+	}
+	if c.qop != "" {
+		fields = append(fields, "nc="+ncValue, fmt.Sprintf(`cnonce="%s"`, cnonce), "qop="+c.qop)
+	}
+	fields = append(fields, fmt.Sprintf(`response="%s"`, response))
+	if c.opaque != "" {
+		fields = append(fields, fmt.Sprintf(`opaque="%s"`, quoteEscaper.Replace(c.opaque)))
+	}
+	return "Digest " + strings.Join(fields, ", ")
+}
+
+// displayAlgorithm - Returns the algorithm with the case of RFC 7616.
+func displayAlgorithm(algorithm string) string {
+	return strings.Replace(algorithm, "-SESS", "-sess", 1)
+}
+
+// selectDigestChallenge - Returns the Digest challenge with the most
+// preferred supported algorithm and qop, nil when there is none.
+func selectDigestChallenge(challenges []authChallenge) *digestChallenge {
+	var selected *digestChallenge
+	rank := len(digestAlgorithms)
+	for _, challenge := range challenges {
+		if !strings.EqualFold(challenge.scheme, "Digest") || challenge.params["nonce"] == "" {
+			continue
+		}
+		algorithm := strings.ToUpper(challenge.params["algorithm"])
+		if algorithm == "" {
+			algorithm = "MD5"
+		}
+		qop := ""
+		if qops, ok := challenge.params["qop"]; ok {
+			for _, option := range strings.Split(qops, ",") {
+				if strings.TrimSpace(option) == "auth" {
+					qop = "auth"
+				}
+			}
+			if qop == "" {
+				continue
+			}
+		}
+		for i, supported := range digestAlgorithms {
+			if algorithm == supported && i < rank {
+				rank = i
+				selected = &digestChallenge{
+					realm:     challenge.params["realm"],
+					nonce:     challenge.params["nonce"],
+					opaque:    challenge.params["opaque"],
+					algorithm: algorithm,
+					qop:       qop,
+					stale:     strings.EqualFold(challenge.params["stale"], "true"),
+				}
+			}
+		}
+	}
+	return selected
+}
+
+func newCnonce() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// authChallenge - A challenge of a WWW-Authenticate header, or the
+// credentials of an Authorization header.
+type authChallenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseChallenges - Parses the challenges of the given WWW-Authenticate
+// header values (RFC 9110 section 11.6.1), several of which may share a
+// value. Parameter names are lowercased.
+func parseChallenges(values []string) []authChallenge {
+	var challenges []authChallenge
+	for _, value := range values {
+		for _, item := range splitAuthItems(value) {
+			name, paramValue, isParam := cutAuthParam(item)
+			if !isParam {
+				scheme := item
+				if i := strings.IndexAny(item, " \t"); i >= 0 {
+					scheme = item[:i]
+					name, paramValue, isParam = cutAuthParam(strings.TrimSpace(item[i:]))
+				}
+				challenges = append(challenges, authChallenge{scheme: scheme, params: make(map[string]string)})
+				if !isParam {
+					continue
+				}
+			}
+			if len(challenges) > 0 {
+				challenges[len(challenges)-1].params[name] = paramValue
+			}
+		}
+	}
+	return challenges
+}
+
+// parseAuthParams - Parses a list of auth params, as found in an
+// Authentication-Info header.
+func parseAuthParams(value string) map[string]string {
+	params := make(map[string]string)
+	for _, item := range splitAuthItems(value) {
+		if name, paramValue, ok := cutAuthParam(item); ok {
+			params[name] = paramValue
+		}
+	}
+	return params
+}
+
+// splitAuthItems - Splits value on the commas outside quoted strings.
+func splitAuthItems(value string) []string {
+	var items []string
+	var item strings.Builder
+	quoted, escaped := false, false
+	for _, r := range value {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			if trimmed := strings.TrimSpace(item.String()); trimmed != "" {
+				items = append(items, trimmed)
+			}
+			item.Reset()
+			continue
+		}
+		item.WriteRune(r)
+	}
+	if trimmed := strings.TrimSpace(item.String()); trimmed != "" {
+		items = append(items, trimmed)
+	}
+	return items
+}
+
+// cutAuthParam - Splits a name=value auth param, unquoting the value.
+func cutAuthParam(item string) (string, string, bool) {
+	i := strings.Index(item, "=")
+	if i <= 0 || strings.ContainsAny(item[:i], " \t\"") {
+		return "", "", false
+	}
+	name := strings.ToLower(strings.TrimSpace(item[:i]))
+	value := strings.TrimSpace(item[i+1:])
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		var unquoted strings.Builder
+		escaped := false
+		for _, r := range value[1 : len(value)-1] {
+			if !escaped && r == '\\' {
+				escaped = true
+				continue
+			}
+			escaped = false
+			unquoted.WriteRune(r)
+		}
+		value = unquoted.String()
+	}
+	return name, value, true
+}
@@ -0,0 +1,185 @@
+package rest
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDigestAuthorizationRFC7616Example(t *testing.T) {
+	challenge := &digestChallenge{
+		realm:  "http-auth@example.org",
+		nonce:  "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+		opaque: "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+		qop:    "auth",
+	}
+	cnonce := "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+
+	challenge.algorithm = "MD5"
+	authorization := challenge.authorization("Mufasa", "Circle of Life", http.MethodGet, "/dir/index.html", 1, cnonce)
+	assert.Equal(t, `Digest username="Mufasa", realm="http-auth@example.org", uri="/dir/index.html", algorithm=MD5, `+
+		`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", nc=00000001, cnonce="f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", `+
+		`qop=auth, response="8ca523f5e9506fed4657c9700eebdbec", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`, authorization)
+
+	challenge.algorithm = "SHA-256"
+	authorization = challenge.authorization("Mufasa", "Circle of Life", http.MethodGet, "/dir/index.html", 1, cnonce)
+	assert.Equal(t, "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+		parseChallenges([]string{authorization})[0].params["response"])
+}
+
+func TestParseChallenges(t *testing.T) {
+	challenges := parseChallenges([]string{
+		`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, nonce="a,b", opaque="o\"q"`,
+		`Basic realm="x", Digest realm="y", nonce=n`,
+	})
+	assert.Equal(t, []authChallenge{
+		{scheme: "Digest", params: map[string]string{
+			"realm": "http-auth@example.org", "qop": "auth, auth-int", "algorithm": "SHA-256", "nonce": "a,b", "opaque": `o"q`}},
+		{scheme: "Basic", params: map[string]string{"realm": "x"}},
+		{scheme: "Digest", params: map[string]string{"realm": "y", "nonce": "n"}},
+	}, challenges)
+
+	selected := selectDigestChallenge(parseChallenges([]string{
+		`Digest realm="r", nonce="md5", qop="auth"`,
+		`Digest realm="r", nonce="sha", algorithm=SHA-256, qop="auth"`,
+		`Digest realm="r", nonce="int", algorithm=SHA-256-sess, qop="auth-int"`,
+	}))
+	assert.Equal(t, "sha", selected.nonce)
+	assert.Equal(t, "SHA-256", selected.algorithm)
+	assert.Nil(t, selectDigestChallenge(parseChallenges([]string{`Basic realm="r"`})))
+}
+
+// digestServer - Local server guarding its resources with Digest auth,
+// checking the nonce counts never go backwards.
+type digestServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	algorithm string
+	nonce     int
+	lastNC    map[string]uint64
+	counts    []uint64
+}
+
+func newDigestServer(algorithm string) *digestServer {
+	server := &digestServer{algorithm: algorithm, nonce: 1, lastNC: make(map[string]uint64)}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serve))
+	return server
+}
+
+func (s *digestServer) rotateNonce() {
+	s.mu.Lock()
+	s.nonce++
+	s.mu.Unlock()
+}
+
+func (s *digestServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nonce := fmt.Sprintf("nonce-%d", s.nonce)
+	stale := false
+	challenges := parseChallenges(r.Header.Values("Authorization"))
+	if len(challenges) == 1 && challenges[0].scheme == "Digest" {
+		params := challenges[0].params
+		newHash := md5.New
+		if strings.HasPrefix(params["algorithm"], "SHA-256") {
+			newHash = sha256.New
+		}
+		h := func(parts ...string) string {
+			return hashHex(newHash(), strings.Join(parts, ":"))
+		}
+		ha1 := h("nsxUser", "devices", "nsxPass")
+		expected := h(ha1, params["nonce"], params["nc"], params["cnonce"], params["qop"], h(r.Method, r.URL.RequestURI()))
+		nc, _ := strconv.ParseUint(params["nc"], 16, 32)
+		switch {
+		case params["response"] != expected || params["uri"] != r.URL.RequestURI() || params["opaque"] != "opaque":
+		case params["nonce"] != nonce:
+			stale = true
+		case nc <= s.lastNC[nonce]:
+		default:
+			s.lastNC[nonce] = nc
+			s.counts = append(s.counts, nc)
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("authorized"))
+			return
+		}
+	}
+	w.Header().Add("WWW-Authenticate", `Basic realm="devices"`)
+	w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Digest realm="devices", qop="auth", algorithm=%s, nonce="%s", opaque="opaque", stale=%t`,
+		s.algorithm, nonce, stale))
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+func hashHex(h hash.Hash, s string) string {
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestDigestAuth(t *testing.T) {
+	for _, algorithm := range []string{"MD5", "SHA-256"} {
+		server := newDigestServer(algorithm)
+
+		client := Client{URL: server.URL, Authenticator: &DigestAuth{User: "nsxUser", Password: "nsxPass"}}
+		out := new(string)
+		api := NewBaseAPI(http.MethodPost, "/devices?page=1", "payload", out, nil)
+		assert.Nil(t, client.Do(api))
+		assert.Equal(t, "authorized", *out)
+		assert.Equal(t, 2, api.Attempts())
+
+		for i := 0; i < 2; i++ {
+			api = NewBaseAPI(http.MethodGet, "/devices", nil, out, nil)
+			assert.Nil(t, client.Do(api))
+			assert.Equal(t, 1, api.Attempts())
+		}
+
+		server.rotateNonce()
+		api = NewBaseAPI(http.MethodGet, "/devices", nil, out, nil)
+		assert.Nil(t, client.Do(api))
+		assert.Equal(t, 2, api.Attempts())
+		assert.Equal(t, []uint64{1, 2, 3, 1}, server.counts)
+
+		server.Close()
+	}
+}
+
+func TestDigestAuthWrongPassword(t *testing.T) {
+
+	server := newDigestServer("SHA-256")
+	defer server.Close()
+
+	client := Client{URL: server.URL, Authenticator: &DigestAuth{User: "nsxUser", Password: "wrong"}}
+	api := NewBaseAPI(http.MethodGet, "/devices", nil, nil, nil)
+	assert.True(t, errors.Is(client.Do(api), ErrUnauthorized))
+	assert.Equal(t, 2, api.Attempts())
+
+	api = NewBaseAPI(http.MethodGet, "/devices", nil, nil, nil)
+	assert.True(t, errors.Is(client.Do(api), ErrUnauthorized))
+	assert.Equal(t, 1, api.Attempts())
+}
+
+func TestDigestAuthNextNonce(t *testing.T) {
+
+	auth := &DigestAuth{User: "nsxUser", Password: "nsxPass"}
+	auth.challenge = &digestChallenge{realm: "devices", nonce: "first", algorithm: "MD5", qop: "auth"}
+	auth.nc = 5
+
+	res := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	res.Header.Set("Authentication-Info", `qop=auth, nextnonce="second", rspauth="x"`)
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	assert.False(t, auth.Reauthenticate(req, res))
+
+	assert.Nil(t, auth.Authenticate(req))
+	params := parseChallenges(req.Header.Values("Authorization"))[0].params
+	assert.Equal(t, "second", params["nonce"])
+	assert.Equal(t, "00000001", params["nc"])
+}
@@ -0,0 +1,109 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrMissingPathParam - Wrapped by the error returned by Client.Do when an
+// endpoint template refers to a path parameter that was not set.
+var ErrMissingPathParam = errors.New("missing path parameter")
+
+// expandEndpoint - Replaces every {name} of an endpoint template with the
+// percent-escaped value of the path parameter name.
+func expandEndpoint(endpoint string, params map[string]string) (string, error) {
+	if !strings.Contains(endpoint, "{") {
+		return endpoint, nil
+	}
+
+	var expanded strings.Builder
+	rest := endpoint
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			expanded.WriteString(rest)
+			return expanded.String(), nil
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated path parameter in endpoint %q", endpoint)
+		}
+		name := rest[start+1 : start+end]
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("%w %q in endpoint %q", ErrMissingPathParam, name, endpoint)
+		}
+		expanded.WriteString(rest[:start])
+		expanded.WriteString(escapePathParam(value))
+		rest = rest[start+end+1:]
+	}
+}
+
+// escapePathParam - Percent-escapes a path parameter so that it always stays
+// a single path segment, "." and ".." included.
+func escapePathParam(value string) string {
+	if value == "." || value == ".." {
+		return strings.Replace(value, ".", "%2E", -1)
+	}
+	return url.PathEscape(value)
+}
+
+// joinURL - Resolves an (already expanded) endpoint against the base URL of
+// a Client. An endpoint with a scheme is used as is; otherwise it is a path,
+// even when it starts with "//", appended to the base path with exactly one
+// slash in between, and its query is added to the base query. Its dot
+// segments are sent as they are, but may not climb above the base path.
+func joinURL(baseURL string, endpoint string) (*url.URL, error) {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpointURL.IsAbs() {
+		return endpointURL, nil
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	endpointPath, endpointQuery, _ := strings.Cut(strings.SplitN(endpoint, "#", 2)[0], "?")
+	joined := *base
+	if endpointPath != "" {
+		if aboveBase(endpointPath) {
+			return nil, fmt.Errorf("endpoint %q climbs above the base path of %q", endpoint, baseURL)
+		}
+		escaped := strings.TrimSuffix(base.EscapedPath(), "/") + "/" + strings.TrimLeft(endpointPath, "/")
+		if joined.Path, err = url.PathUnescape(escaped); err != nil {
+			return nil, err
+		}
+		joined.RawPath = escaped
+	}
+	switch {
+	case base.RawQuery == "":
+		joined.RawQuery = endpointQuery
+	case endpointQuery != "":
+		joined.RawQuery = base.RawQuery + "&" + endpointQuery
+	}
+	joined.Fragment = ""
+	return &joined, nil
+}
+
+// aboveBase - Tells whether the ".." segments of an endpoint path climb
+// above the path it is appended to.
+func aboveBase(endpointPath string) bool {
+	depth := 0
+	for _, segment := range strings.Split(strings.TrimLeft(endpointPath, "/"), "/") {
+		switch segment {
+		case "..":
+			if depth--; depth < 0 {
+				return true
+			}
+		case ".", "":
+		default:
+			depth++
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExpandEndpoint(t *testing.T) {
+	params := map[string]string{"name": "web pool/1", "id": "42", "dots": ".."}
+
+	endpoint, err := expandEndpoint("/vservers/{name}/pools/{id}", params)
+	assert.Nil(t, err)
+	assert.Equal(t, "/vservers/web%20pool%2F1/pools/42", endpoint)
+
+	endpoint, err = expandEndpoint("/files/{dots}/x", params)
+	assert.Nil(t, err)
+	assert.Equal(t, "/files/%2E%2E/x", endpoint)
+
+	endpoint, err = expandEndpoint("/plain", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "/plain", endpoint)
+
+	_, err = expandEndpoint("/vservers/{other}", params)
+	assert.True(t, errors.Is(err, ErrMissingPathParam))
+
+	_, err = expandEndpoint("/vservers/{name", params)
+	assert.NotNil(t, err)
+}
+
+func TestJoinURL(t *testing.T) {
+	for _, c := range []struct{ base, endpoint, expected string }{
+		{"http://www.example.com/", "/", "http://www.example.com/"},
+		{"http://www.example.com", "/", "http://www.example.com/"},
+		{"http://www.example.com", "", "http://www.example.com"},
+		{"http://host/api/", "/status", "http://host/api/status"},
+		{"http://host/api", "status", "http://host/api/status"},
+		{"http://host/api", "/items/", "http://host/api/items/"},
+		{"http://host/api?key=1", "/items?page=2", "http://host/api/items?key=1&page=2"},
+		{"http://host/api", "/a%2Fb/c", "http://host/api/a%2Fb/c"},
+		{"http://host/api", "https://other/x?y=1", "https://other/x?y=1"},
+		{"http://host/api", "//vservers/x", "http://host/api/vservers/x"},
+		{"http://host/api/", "//vservers/x?y=1", "http://host/api/vservers/x?y=1"},
+		{"http://host/api", "/a/../b", "http://host/api/a/../b"},
+	} {
+		u, err := joinURL(c.base, c.endpoint)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, u.String(), c.base+" + "+c.endpoint)
+	}
+
+	for _, endpoint := range []string{"/a/../../b", "..", "/./../b"} {
+		_, err := joinURL("http://host/api", endpoint)
+		assert.NotNil(t, err, endpoint)
+	}
+}
+
+func TestPathTemplateRequest(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/tm/vservers/web%20pool%2F1/pools/42", r.URL.EscapedPath())
+		assert.Equal(t, "2", r.URL.Query().Get("page"))
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL + "/api/tm/"}
+	api := NewBaseAPI(http.MethodGet, "/vservers/{name}/pools/{id}", nil, nil, nil)
+	api.SetPathParam("name", "web pool/1")
+	api.SetPathParam("id", "42")
+	api.Query().Set("page", "2")
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, http.StatusOK, api.StatusCode())
+
+	api = NewBaseAPI(http.MethodGet, "/vservers/{name}", nil, nil, nil)
+	assert.True(t, errors.Is(client.Do(api), ErrMissingPathParam))
+}
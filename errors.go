@@ -0,0 +1,174 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors matched by an *HTTPError with errors.Is, e.g.
+// errors.Is(err, rest.ErrNotFound).
+var (
+	ErrBadRequest          = errors.New("bad request")
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrForbidden           = errors.New("forbidden")
+	ErrNotFound            = errors.New("not found")
+	ErrMethodNotAllowed    = errors.New("method not allowed")
+	ErrConflict            = errors.New("conflict")
+	ErrGone                = errors.New("gone")
+	ErrPreconditionFailed  = errors.New("precondition failed")
+	ErrUnprocessableEntity = errors.New("unprocessable entity")
+	ErrTooManyRequests     = errors.New("too many requests")
+	ErrInternalServerError = errors.New("internal server error")
+	ErrBadGateway          = errors.New("bad gateway")
+	ErrServiceUnavailable  = errors.New("service unavailable")
+	ErrGatewayTimeout      = errors.New("gateway timeout")
+
+	// ErrClientError - Matches any 4xx *HTTPError.
+	ErrClientError = errors.New("client error")
+	// ErrServerError - Matches any 5xx *HTTPError.
+	ErrServerError = errors.New("server error")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:          ErrBadRequest,
+	http.StatusUnauthorized:        ErrUnauthorized,
+	http.StatusForbidden:           ErrForbidden,
+	http.StatusNotFound:            ErrNotFound,
+	http.StatusMethodNotAllowed:    ErrMethodNotAllowed,
+	http.StatusConflict:            ErrConflict,
+	http.StatusGone:                ErrGone,
+	http.StatusPreconditionFailed:  ErrPreconditionFailed,
+	http.StatusUnprocessableEntity: ErrUnprocessableEntity,
+	http.StatusTooManyRequests:     ErrTooManyRequests,
+	http.StatusInternalServerError: ErrInternalServerError,
+	http.StatusBadGateway:          ErrBadGateway,
+	http.StatusServiceUnavailable:  ErrServiceUnavailable,
+	http.StatusGatewayTimeout:      ErrGatewayTimeout,
+}
+
+// HTTPError - Error returned by Client.Do when the server answers with a
+// 4xx or 5xx status code.
+type HTTPError struct {
+	StatusCode int
+	Method     string
+	URL        string
+	Header     http.Header
+	// Body - The raw response payload.
+	Body []byte
+	// ErrorObject - The BaseAPI error object the payload was decoded into,
+	// nil when there was none or decoding failed.
+	ErrorObject interface{}
+}
+
+// newHTTPError - Builds the error for a failed response to apiObj.
+func newHTTPError(apiObj *BaseAPI, res *http.Response, body []byte, errorObject interface{}) *HTTPError {
+	httpErr := &HTTPError{
+		StatusCode:  res.StatusCode,
+		Method:      apiObj.Method(),
+		Header:      res.Header,
+		Body:        body,
+		ErrorObject: errorObject,
+	}
+	if res.Request != nil {
+		httpErr.Method = res.Request.Method
+		httpErr.URL = apiObj.FinalURL()
+	}
+	return httpErr
+}
+
+// Error - Returns the error message.
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("Response status code: %d", e.StatusCode)
+}
+
+// Is - Reports whether target is the sentinel error for the status code,
+// ErrClientError or ErrServerError.
+func (e *HTTPError) Is(target error) bool {
+	if sentinel, ok := statusErrors[e.StatusCode]; ok && sentinel == target {
+		return true
+	}
+	switch target {
+	case ErrClientError:
+		return e.StatusCode >= 400 && e.StatusCode < 500
+	case ErrServerError:
+		return e.StatusCode >= 500 && e.StatusCode < 600
+	}
+	return false
+}
+
+// EncodeError - Error returned by Client.Do when the request object cannot
+// be encoded for the request content type.
+type EncodeError struct {
+	ContentType string
+	Err         error
+}
+
+// Error - Returns the error message.
+func (e *EncodeError) Error() string {
+	return fmt.Sprintf("Error encoding request payload as %s: %v", e.ContentType, e.Err)
+}
+
+// Unwrap - Returns the underlying marshalling error.
+func (e *EncodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError - Error returned by Client.Do when a response payload cannot
+// be decoded into the response object.
+type DecodeError struct {
+	ContentType string
+	Err         error
+}
+
+// Error - Returns the error message.
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("Error decoding response payload as %s: %v", e.ContentType, e.Err)
+}
+
+// Unwrap - Returns the underlying unmarshalling error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ErrNotAcceptable - Matched by a *NotAcceptableError with errors.Is.
+var ErrNotAcceptable = errors.New("response content type not acceptable")
+
+// NotAcceptableError - Error returned by Client.Do when a successful response
+// has a media type that cannot be decoded into the response object, or that
+// is not accepted by the Accept header set by the caller.
+type NotAcceptableError struct {
+	ContentType string
+	Accept      string
+}
+
+// Error - Returns the error message.
+func (e *NotAcceptableError) Error() string {
+	return fmt.Sprintf("Response content type %q is not acceptable (Accept: %s)", e.ContentType, e.Accept)
+}
+
+// Is - Reports whether target is ErrNotAcceptable.
+func (e *NotAcceptableError) Is(target error) bool {
+	return target == ErrNotAcceptable
+}
+
+// ErrResponseTooLarge - Matched by a *ResponseTooLargeError with errors.Is.
+var ErrResponseTooLarge = errors.New("response payload too large")
+
+// ResponseTooLargeError - Error returned by Client.Do when a response payload
+// exceeds Client.MaxResponseBodySize.
+type ResponseTooLargeError struct {
+	Limit int64
+	// ContentLength - The announced payload length, -1 when unknown.
+	ContentLength int64
+}
+
+// Error - Returns the error message.
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("Response payload exceeds the limit of %d bytes", e.Limit)
+}
+
+// Is - Reports whether target is ErrResponseTooLarge.
+func (e *ResponseTooLargeError) Is(target error) bool {
+	return target == ErrResponseTooLarge
+}
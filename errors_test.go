@@ -0,0 +1,96 @@
+package rest
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPErrorIsTyped(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "req-1")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error_id":"resource.not_found"}`))
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	api := NewBaseAPI(http.MethodGet, "/missing", nil, nil, new(ErrStruct))
+	err := client.Do(api)
+
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.True(t, errors.Is(err, ErrClientError))
+	assert.False(t, errors.Is(err, ErrConflict))
+	assert.False(t, errors.Is(err, ErrServerError))
+	assert.Equal(t, "Response status code: 404", err.Error())
+
+	var httpErr *HTTPError
+	assert.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+	assert.Equal(t, http.MethodGet, httpErr.Method)
+	assert.Equal(t, ts.URL+"/missing", httpErr.URL)
+	assert.Equal(t, "req-1", httpErr.Header.Get("X-Request-Id"))
+	assert.Equal(t, `{"error_id":"resource.not_found"}`, string(httpErr.Body))
+	assert.Equal(t, "resource.not_found", httpErr.ErrorObject.(*ErrStruct).ErrID)
+}
+
+func TestHTTPErrorWithoutBody(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	err := client.Do(NewBaseAPI(http.MethodPut, "/", nil, nil, new(ErrStruct)))
+
+	assert.True(t, errors.Is(err, ErrConflict))
+	var httpErr *HTTPError
+	assert.True(t, errors.As(err, &httpErr))
+	assert.Nil(t, httpErr.ErrorObject)
+	assert.Equal(t, http.MethodPut, httpErr.Method)
+}
+
+func TestHTTPErrorIs(t *testing.T) {
+	assert.True(t, errors.Is(&HTTPError{StatusCode: 401}, ErrUnauthorized))
+	assert.True(t, errors.Is(&HTTPError{StatusCode: 503}, ErrServiceUnavailable))
+	assert.True(t, errors.Is(&HTTPError{StatusCode: 599}, ErrServerError))
+	assert.False(t, errors.Is(&HTTPError{StatusCode: 418}, ErrServerError))
+	assert.True(t, errors.Is(&HTTPError{StatusCode: 418}, ErrClientError))
+}
+
+func TestEncodeErrors(t *testing.T) {
+
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL, Headers: map[string]string{"Content-Type": "application/json"}}
+	err := client.Do(NewBaseAPI(http.MethodPost, "/", make(chan int), nil, nil))
+	var encodeErr *EncodeError
+	assert.True(t, errors.As(err, &encodeErr))
+	assert.Equal(t, "application/json", encodeErr.ContentType)
+	assert.NotNil(t, errors.Unwrap(err))
+
+	client = Client{URL: ts.URL, Headers: map[string]string{"Content-Type": "application/xml"}}
+	err = client.Do(NewBaseAPI(http.MethodPost, "/", map[string]string{"foo": "bar"}, nil, nil))
+	assert.True(t, errors.As(err, &encodeErr))
+	assert.Equal(t, "application/xml", encodeErr.ContentType)
+
+	client = Client{URL: ts.URL, Headers: map[string]string{"Content-Type": "application/octet-stream"}}
+	err = client.Do(NewBaseAPI(http.MethodPost, "/", ReqBody{}, nil, nil))
+	assert.True(t, errors.As(err, &encodeErr))
+	assert.Equal(t, "application/octet-stream", encodeErr.ContentType)
+
+	assert.Equal(t, 0, calls)
+
+	client = Client{URL: ts.URL, Headers: map[string]string{"Content-Type": "text/plain"}}
+	assert.Nil(t, client.Do(NewBaseAPI(http.MethodPost, "/", "plain text", nil, nil)))
+	assert.Equal(t, 1, calls)
+}
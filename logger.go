@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// Logger - Receives the diagnostics of a Client. keysAndValues alternate
+// field names and values, e.g. "method", "GET", "status", 200.
+// A *slog.Logger satisfies this interface.
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// NopLogger - Logger discarding everything; the default when Client.Logger
+// is not set and Client.Debug is off.
+var NopLogger Logger = nopLogger{}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+// NewSlogLogger - Returns a Logger writing to l, or to slog.Default() when l
+// is nil.
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return l
+}
+
+// StdLogger - Logger writing to the standard log package with [TRACE],
+// [INFO], [WARN] and [ERROR] prefixes; the default when Client.Logger is not
+// set and Client.Debug is on.
+var StdLogger Logger = stdLogger{}
+
+type stdLogger struct{}
+
+func (stdLogger) Debug(msg string, kv ...interface{}) { stdLog("TRACE", msg, kv) }
+func (stdLogger) Info(msg string, kv ...interface{})  { stdLog("INFO", msg, kv) }
+func (stdLogger) Warn(msg string, kv ...interface{})  { stdLog("WARN", msg, kv) }
+func (stdLogger) Error(msg string, kv ...interface{}) { stdLog("ERROR", msg, kv) }
+
+func stdLog(level string, msg string, kv []interface{}) {
+	var line strings.Builder
+	fmt.Fprintf(&line, "[%s] %s", level, msg)
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 < len(kv) {
+			fmt.Fprintf(&line, " %v=%v", kv[i], kv[i+1])
+		} else {
+			fmt.Fprintf(&line, " %v", kv[i])
+		}
+	}
+	log.Println(line.String())
+}
+
+// logger - Returns the Logger of restClient, falling back to StdLogger when
+// Debug is on and to NopLogger otherwise.
+func (restClient *Client) logger() Logger {
+	if restClient.Logger != nil {
+		return restClient.Logger
+	}
+	if restClient.Debug {
+		return StdLogger
+	}
+	return NopLogger
+}
+
+// callLog - Logger adding the fields identifying one call to every entry.
+type callLog struct {
+	logger Logger
+	fields []interface{}
+}
+
+func newCallLog(logger Logger, keysAndValues ...interface{}) *callLog {
+	return &callLog{logger: logger, fields: keysAndValues}
+}
+
+func (l *callLog) with(keysAndValues []interface{}) []interface{} {
+	fields := make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+	return append(append(fields, l.fields...), keysAndValues...)
+}
+
+func (l *callLog) debug(msg string, keysAndValues ...interface{}) {
+	l.logger.Debug(msg, l.with(keysAndValues)...)
+}
+
+func (l *callLog) warn(msg string, keysAndValues ...interface{}) {
+	l.logger.Warn(msg, l.with(keysAndValues)...)
+}
+
+func (l *callLog) error(msg string, keysAndValues ...interface{}) {
+	l.logger.Error(msg, l.with(keysAndValues)...)
+}
+
+// newRequestID - Returns a random identifier correlating the log entries of
+// one call.
+func newRequestID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
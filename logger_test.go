@@ -0,0 +1,86 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSlogLoggerStructuredFields(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("pong"))
+	}))
+	defer ts.Close()
+
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := Client{URL: ts.URL, Logger: NewSlogLogger(logger)}
+	assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/ping", nil, new(string), nil)))
+
+	var completed map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var entry map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &entry))
+		assert.Equal(t, "DEBUG", entry["level"])
+		assert.Equal(t, http.MethodGet, entry["method"])
+		assert.Equal(t, ts.URL+"/ping", entry["url"])
+		assert.NotEmpty(t, entry["request_id"])
+		if entry["msg"] == "Request completed" {
+			completed = entry
+		}
+	}
+	assert.NotNil(t, completed)
+	assert.Equal(t, float64(http.StatusOK), completed["status"])
+	assert.Equal(t, float64(1), completed["attempt"])
+	assert.NotNil(t, completed["duration"])
+	assert.NotContains(t, out.String(), "pong")
+}
+
+func TestErrorsLoggedAtErrorLevel(t *testing.T) {
+
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelError}))
+	client := Client{
+		URL:     "http://127.0.0.1:1",
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Logger:  logger,
+	}
+	assert.NotNil(t, client.Do(NewBaseAPI(http.MethodPost, "/", make(chan int), nil, nil)))
+
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "ERROR", entry["level"])
+	assert.Equal(t, "Error encoding request payload", entry["msg"])
+	assert.Equal(t, "application/json", entry["content_type"])
+}
+
+func TestDefaultLoggers(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("pong"))
+	}))
+	defer ts.Close()
+
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	client := Client{URL: ts.URL}
+	assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/", nil, new(string), nil)))
+	assert.Equal(t, "", out.String())
+
+	client = Client{URL: ts.URL, Debug: true}
+	assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/", nil, new(string), nil)))
+	assert.Contains(t, out.String(), "[TRACE] Response payload")
+	assert.Contains(t, out.String(), "payload=pong")
+}
@@ -0,0 +1,227 @@
+package rest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Multipart - Builds a multipart/form-data request payload out of text fields
+// and file parts. The payload is streamed as it is sent, without being
+// buffered in memory.
+type Multipart struct {
+	boundary string
+	parts    []multipartPart
+}
+
+type multipartPart struct {
+	header textproto.MIMEHeader
+	value  string
+	reader io.Reader
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// NewMultipart - Returns an empty Multipart with a random boundary.
+func NewMultipart() *Multipart {
+	return &Multipart{boundary: multipart.NewWriter(ioutil.Discard).Boundary()}
+}
+
+// Boundary - Returns the boundary separating the parts.
+func (m *Multipart) Boundary() string {
+	return m.boundary
+}
+
+// SetBoundary - Overrides the random boundary, which must be 1 to 70 valid
+// characters as required by RFC 2046.
+func (m *Multipart) SetBoundary(boundary string) error {
+	if err := multipart.NewWriter(ioutil.Discard).SetBoundary(boundary); err != nil {
+		return fmt.Errorf("invalid multipart boundary %q: %w", boundary, err)
+	}
+	m.boundary = boundary
+	return nil
+}
+
+// ContentType - Returns the multipart/form-data Content-Type, boundary
+// included.
+func (m *Multipart) ContentType() string {
+	return "multipart/form-data; boundary=" + quoteBoundary(m.boundary)
+}
+
+// AddField - Adds a text field.
+func (m *Multipart) AddField(name, value string) {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(name)))
+	m.parts = append(m.parts, multipartPart{header: header, value: value})
+}
+
+// AddFile - Adds a file part read from r, application/octet-stream when
+// contentType is empty. The Multipart can only be sent again, by retries and
+// redirects, when the readers of all its file parts are io.Seekers.
+func (m *Multipart) AddFile(name, filename, contentType string, r io.Reader) {
+	if contentType == "" {
+		contentType = streamContentType
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(name), quoteEscaper.Replace(filename)))
+	header.Set("Content-Type", contentType)
+	m.parts = append(m.parts, multipartPart{header: header, reader: r})
+}
+
+// write - Writes the payload to w. The content of the file parts is only
+// copied when copyFiles is set, to measure the length of the rest.
+func (m *Multipart) write(w io.Writer, copyFiles bool) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(m.boundary); err != nil {
+		return err
+	}
+	for _, part := range m.parts {
+		pw, err := mw.CreatePart(part.header)
+		if err != nil {
+			return err
+		}
+		if part.reader == nil {
+			_, err = io.WriteString(pw, part.value)
+		} else if copyFiles {
+			_, err = io.Copy(pw, part.reader)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// multipartReader - Streams the payload of a Multipart through a pipe, from
+// a goroutine started by the first Read, so that a request that is never
+// sent holds no goroutine.
+type multipartReader struct {
+	m *Multipart
+
+	mu     sync.Mutex
+	pipe   *io.PipeReader
+	done   chan struct{}
+	closed bool
+}
+
+func (r *multipartReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	if r.pipe == nil {
+		pr, pw := io.Pipe()
+		r.pipe = pr
+		r.done = make(chan struct{})
+		go func() {
+			defer close(r.done)
+			pw.CloseWithError(r.m.write(pw, true))
+		}()
+	}
+	pipe := r.pipe
+	r.mu.Unlock()
+	return pipe.Read(p)
+}
+
+// Close - Stops the goroutine writing the payload, and waits for it to be
+// done with the readers of the file parts.
+func (r *multipartReader) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	pipe, done := r.pipe, r.done
+	r.mu.Unlock()
+	if pipe == nil {
+		return nil
+	}
+	pipe.Close()
+	<-done
+	return nil
+}
+
+// multipartBody - Sets m as the body of req. When the readers of all the
+// file parts are io.Seekers, the body is sent with its Content-Length and
+// rewound through GetBody; otherwise it is sent chunked, and only once.
+func multipartBody(req *http.Request, m *Multipart) error {
+
+	body := &multipartReader{m: m}
+	req.Body = body
+	req.GetBody = nil
+	req.ContentLength = -1
+
+	var starts []int64
+	var length int64
+	for _, part := range m.parts {
+		if part.reader == nil {
+			continue
+		}
+		seeker, ok := part.reader.(io.Seeker)
+		if !ok {
+			return nil
+		}
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		starts = append(starts, start)
+		length += end - start
+	}
+
+	counter := &countingWriter{}
+	if err := m.write(counter, false); err != nil {
+		return err
+	}
+	req.ContentLength = length + counter.n
+	var mu sync.Mutex
+	req.GetBody = func() (io.ReadCloser, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// The previous body may still be read by the transport: its writer
+		// must be done with the file readers before they are rewound.
+		body.Close()
+		i := 0
+		for _, part := range m.parts {
+			if part.reader == nil {
+				continue
+			}
+			if _, err := part.reader.(io.Seeker).Seek(starts[i], io.SeekStart); err != nil {
+				return nil, err
+			}
+			i++
+		}
+		body = &multipartReader{m: m}
+		return body, nil
+	}
+	return nil
+}
+
+// quoteBoundary - Quotes boundaries holding characters not allowed in a
+// media type parameter token.
+func quoteBoundary(boundary string) string {
+	if strings.ContainsAny(boundary, "()<>@,;:\\\"/[]?= ") {
+		return `"` + boundary + `"`
+	}
+	return boundary
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
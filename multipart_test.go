@@ -0,0 +1,187 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type receivedPart struct {
+	Name        string
+	FileName    string
+	ContentType string
+	Content     string
+}
+
+type multipartRecord struct {
+	Parts         []receivedPart
+	ContentLength int64
+	Boundary      string
+	Err           error
+}
+
+func newMultipartServer(record *multipartRecord, statuses ...int) *httptest.Server {
+	calls := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*record = multipartRecord{ContentLength: r.ContentLength}
+		mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "multipart/form-data" {
+			record.Err = err
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		record.Boundary = params["boundary"]
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				record.Err = err
+				break
+			}
+			data, _ := ioutil.ReadAll(part)
+			record.Parts = append(record.Parts, receivedPart{
+				Name:        part.FormName(),
+				FileName:    part.FileName(),
+				ContentType: part.Header.Get("Content-Type"),
+				Content:     string(data),
+			})
+		}
+		if calls < len(statuses) {
+			w.WriteHeader(statuses[calls])
+		}
+		calls++
+	}))
+}
+
+func TestMultipartUpload(t *testing.T) {
+
+	var record multipartRecord
+	ts := newMultipartServer(&record)
+	defer ts.Close()
+
+	form := NewMultipart()
+	form.AddField("description", "quarterly report")
+	form.AddFile("report", `q1 "final".csv`, "text/csv", strings.NewReader("a,b\n1,2\n"))
+	form.AddFile("attachment", "blob.bin", "", bytes.NewReader([]byte{0, 1, 2}))
+	api := NewBaseAPI(http.MethodPost, "/reports", nil, nil, nil)
+	api.SetMultipart(form)
+
+	client := Client{URL: ts.URL}
+	assert.Nil(t, client.Do(api))
+	assert.Nil(t, record.Err)
+	assert.Equal(t, form.Boundary(), record.Boundary)
+	assert.True(t, record.ContentLength > 0)
+	assert.Equal(t, []receivedPart{
+		{Name: "description", Content: "quarterly report"},
+		{Name: "report", FileName: `q1 "final".csv`, ContentType: "text/csv", Content: "a,b\n1,2\n"},
+		{Name: "attachment", FileName: "blob.bin", ContentType: "application/octet-stream", Content: "\x00\x01\x02"},
+	}, record.Parts)
+}
+
+func TestMultipartUploadIsChunkedWhenNotSeekable(t *testing.T) {
+
+	var record multipartRecord
+	ts := newMultipartServer(&record, http.StatusServiceUnavailable)
+	defer ts.Close()
+
+	form := NewMultipart()
+	form.AddFile("file", "data.txt", "text/plain", io.MultiReader(strings.NewReader("streamed")))
+	api := NewBaseAPI(http.MethodPut, "/files", nil, nil, nil)
+	api.SetMultipart(form)
+
+	client := Client{URL: ts.URL, RetryPolicy: testRetryPolicy()}
+	assert.NotNil(t, client.Do(api))
+	assert.Equal(t, 1, api.Attempts())
+	assert.Equal(t, int64(-1), record.ContentLength)
+	assert.Equal(t, "streamed", record.Parts[0].Content)
+}
+
+func TestMultipartUploadIsRewoundOnRetry(t *testing.T) {
+
+	var record multipartRecord
+	ts := newMultipartServer(&record, http.StatusServiceUnavailable)
+	defer ts.Close()
+
+	form := NewMultipart()
+	assert.Nil(t, form.SetBoundary("custom-boundary"))
+	form.AddFile("file", "data.txt", "text/plain", strings.NewReader("seekable"))
+	api := NewBaseAPI(http.MethodPut, "/files", nil, nil, nil)
+	api.SetMultipart(form)
+
+	client := Client{URL: ts.URL, RetryPolicy: testRetryPolicy()}
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, 2, api.Attempts())
+	assert.Equal(t, "custom-boundary", record.Boundary)
+	assert.Equal(t, "seekable", record.Parts[0].Content)
+}
+
+func TestMultipartBoundary(t *testing.T) {
+
+	form := NewMultipart()
+	assert.NotEqual(t, form.Boundary(), NewMultipart().Boundary())
+	assert.NotNil(t, form.SetBoundary(""))
+	assert.NotNil(t, form.SetBoundary(strings.Repeat("b", 71)))
+	assert.Nil(t, form.SetBoundary("with space"))
+	assert.Equal(t, `multipart/form-data; boundary="with space"`, form.ContentType())
+}
+
+func TestLargeMultipartUploadIsRewoundOnRetry(t *testing.T) {
+
+	var calls, received int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		n, _ := io.Copy(ioutil.Discard, file)
+		atomic.StoreInt32(&received, int32(n))
+	}))
+	defer ts.Close()
+
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 2*1024*1024)
+	form := NewMultipart()
+	form.AddFile("file", "large.bin", "", bytes.NewReader(payload))
+	api := NewBaseAPI(http.MethodPut, "/files", nil, nil, nil)
+	api.SetMultipart(form)
+
+	client := Client{URL: ts.URL, RetryPolicy: testRetryPolicy()}
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, 2, api.Attempts())
+	assert.Equal(t, int32(len(payload)), atomic.LoadInt32(&received))
+}
+
+func TestMultipartNotStreamedWhenNotSent(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	failing := AuthenticatorFunc(func(*http.Request) error { return ErrMissingCredentials })
+	client := Client{URL: ts.URL, Authenticator: failing}
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		form := NewMultipart()
+		form.AddFile("file", "data.txt", "text/plain", io.MultiReader(strings.NewReader("never sent")))
+		api := NewBaseAPI(http.MethodPost, "/files", nil, nil, nil)
+		api.SetMultipart(form)
+		assert.True(t, errors.Is(client.Do(api), ErrMissingCredentials))
+	}
+	assert.True(t, runtime.NumGoroutine() < before+5)
+}
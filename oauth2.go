@@ -0,0 +1,221 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultExpiryDelta - How long before its expiry a cached OAuth2 token is
+// refreshed, when ClientCredentials.ExpiryDelta is zero.
+const DefaultExpiryDelta = 10 * time.Second
+
+// Token - An OAuth2 access token, as answered by a token endpoint (RFC 6749
+// section 5.1) in JSON or form-encoded.
+type Token struct {
+	AccessToken string `json:"access_token" url:"access_token"`
+	TokenType   string `json:"token_type" url:"token_type"`
+	ExpiresIn   int64  `json:"expires_in,omitempty" url:"expires_in,omitempty"`
+	Scope       string `json:"scope,omitempty" url:"scope,omitempty"`
+
+	// Expiry - When the token expires, zero when the endpoint did not tell.
+	Expiry time.Time `json:"-" url:"-"`
+}
+
+// valid - Tells whether the token can still be used at now, delta before
+// its expiry.
+func (t *Token) valid(now time.Time, delta time.Duration) bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || now.Add(delta).Before(t.Expiry))
+}
+
+// TokenError - Error object of a token endpoint (RFC 6749 section 5.2),
+// found as the ErrorObject of the HTTPError returned when a token cannot be
+// obtained.
+type TokenError struct {
+	Code        string `json:"error" url:"error"`
+	Description string `json:"error_description,omitempty" url:"error_description,omitempty"`
+	URI         string `json:"error_uri,omitempty" url:"error_uri,omitempty"`
+}
+
+// Error - Returns the OAuth2 error code and its description.
+func (e *TokenError) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("OAuth2 error: %s", e.Code)
+	}
+	return fmt.Sprintf("OAuth2 error: %s: %s", e.Code, e.Description)
+}
+
+// ClientCredentials - Authenticator obtaining Bearer tokens from TokenURL
+// with the OAuth2 client credentials grant (RFC 6749 section 4.4). Tokens are
+// cached until ExpiryDelta before they expire; concurrent calls share a
+// single token request. A request answered with 401 Unauthorized is sent
+// once more with a new token.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// EndpointParams - Additional parameters of the token requests, e.g. an
+	// audience.
+	EndpointParams url.Values
+
+	// AuthInBody - Sends the client credentials as form parameters rather
+	// than with basic authentication.
+	AuthInBody bool
+
+	// ExpiryDelta - How long before their expiry tokens are refreshed,
+	// DefaultExpiryDelta when zero.
+	ExpiryDelta time.Duration
+
+	// Client - Optional Client the token requests are sent with, e.g. to set
+	// a Transport or a Logger. Its Authenticator is not used.
+	Client *Client
+
+	mu      sync.Mutex
+	token   *Token
+	refresh *tokenRefresh
+	now     func() time.Time
+
+	defaultClientOnce sync.Once
+	defaultClient     *Client
+}
+
+// tokenRefresh - A token request in flight, waited for by the concurrent
+// calls needing a token.
+type tokenRefresh struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+// Authenticate - Sets the Authorization header, requesting a token first
+// when there is no valid one.
+func (c *ClientCredentials) Authenticate(req *http.Request) error {
+	token, err := c.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return nil
+}
+
+// Reauthenticate - Drops the token req was sent with when it was answered
+// with 401 Unauthorized, so that the request is sent again with a new one.
+func (c *ClientCredentials) Reauthenticate(req *http.Request, res *http.Response) bool {
+	if res.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != nil && req.Header.Get("Authorization") == "Bearer "+c.token.AccessToken {
+		c.token = nil
+	}
+	return true
+}
+
+// Token - Returns the cached token, or a new one when it is missing or about
+// to expire.
+func (c *ClientCredentials) Token(ctx context.Context) (*Token, error) {
+	c.mu.Lock()
+	if c.token.valid(c.clock(), c.expiryDelta()) {
+		token := c.token
+		c.mu.Unlock()
+		return token, nil
+	}
+	refresh := c.refresh
+	if refresh == nil {
+		refresh = &tokenRefresh{done: make(chan struct{})}
+		c.refresh = refresh
+		go c.runRefresh(ctx, refresh)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-refresh.done:
+		return refresh.token, refresh.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// runRefresh - Requests a token for refresh. The request is shared by all
+// the calls waiting for it, so that it is not cancelled with the context of
+// the call that started it; each call only stops waiting on its own.
+func (c *ClientCredentials) runRefresh(ctx context.Context, refresh *tokenRefresh) {
+	ctx, cancel := detachedContext(ctx)
+	defer cancel()
+	token, err := c.requestToken(ctx)
+
+	c.mu.Lock()
+	c.refresh = nil
+	refresh.token, refresh.err = token, err
+	if err == nil {
+		c.token = token
+	}
+	c.mu.Unlock()
+	close(refresh.done)
+}
+
+// requestToken - Sends a client credentials token request to TokenURL.
+func (c *ClientCredentials) requestToken(ctx context.Context) (*Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	for name, values := range c.EndpointParams {
+		form[name] = values
+	}
+	if c.AuthInBody {
+		form.Set("client_id", c.ClientID)
+		form.Set("client_secret", c.ClientSecret)
+	}
+
+	token := new(Token)
+	api := NewBaseAPI(http.MethodPost, c.TokenURL, form, token, new(TokenError))
+	api.SetContentType("application/x-www-form-urlencoded")
+	api.SetHeader("Accept", "application/json, application/x-www-form-urlencoded;q=0.5")
+	api.SetAuthenticator(noAuthentication)
+	if !c.AuthInBody {
+		api.SetAuthenticator(&BasicAuth{User: url.QueryEscape(c.ClientID), Password: url.QueryEscape(c.ClientSecret)})
+	}
+	requestedAt := c.clock()
+	if err := c.client().DoContext(ctx, api); err != nil {
+		return nil, fmt.Errorf("requesting OAuth2 token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("requesting OAuth2 token: %w", &TokenError{Code: "invalid_response", Description: "no access_token in the response"})
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = requestedAt.Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+func (c *ClientCredentials) client() *Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	c.defaultClientOnce.Do(func() {
+		c.defaultClient = &Client{}
+	})
+	return c.defaultClient
+}
+
+func (c *ClientCredentials) expiryDelta() time.Duration {
+	if c.ExpiryDelta != 0 {
+		return c.ExpiryDelta
+	}
+	return DefaultExpiryDelta
+}
+
+func (c *ClientCredentials) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
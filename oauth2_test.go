@@ -0,0 +1,221 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type tokenServer struct {
+	*httptest.Server
+	requests int32
+	forms    chan map[string]string
+}
+
+// newTokenServer - Token endpoint handing out tokens t1, t2... valid for
+// expiresIn seconds.
+func newTokenServer(expiresIn int, delay time.Duration) *tokenServer {
+	server := &tokenServer{forms: make(chan map[string]string, 100)}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&server.requests, 1)
+		r.ParseForm()
+		user, password, _ := r.BasicAuth()
+		server.forms <- map[string]string{
+			"grant_type":    r.PostForm.Get("grant_type"),
+			"scope":         r.PostForm.Get("scope"),
+			"audience":      r.PostForm.Get("audience"),
+			"client_id":     r.PostForm.Get("client_id"),
+			"client_secret": r.PostForm.Get("client_secret"),
+			"basic":         user + ":" + password,
+		}
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("client_secret") == "wrong" || password == "wrong" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"unknown client"}`))
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"t%d","token_type":"bearer","expires_in":%d}`, n, expiresIn)
+	}))
+	return server
+}
+
+// newProtectedServer - API server accepting the tokens for which valid
+// returns true, and echoing the one it was sent.
+func newProtectedServer(valid func(token string) bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !valid(token) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(token))
+	}))
+}
+
+func TestClientCredentialsTokenIsCached(t *testing.T) {
+
+	tokens := newTokenServer(3600, 0)
+	defer tokens.Close()
+	ts := newProtectedServer(func(string) bool { return true })
+	defer ts.Close()
+
+	credentials := &ClientCredentials{
+		TokenURL:       tokens.URL + "/oauth/token",
+		ClientID:       "my app",
+		ClientSecret:   "s3cr3t",
+		Scopes:         []string{"read", "write"},
+		EndpointParams: map[string][]string{"audience": {"api"}},
+	}
+	client := Client{URL: ts.URL, Authenticator: credentials}
+	for i := 0; i < 3; i++ {
+		out := new(string)
+		assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/", nil, out, nil)))
+		assert.Equal(t, "t1", *out)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tokens.requests))
+	assert.Equal(t, map[string]string{
+		"grant_type":    "client_credentials",
+		"scope":         "read write",
+		"audience":      "api",
+		"client_id":     "",
+		"client_secret": "",
+		"basic":         "my+app:s3cr3t",
+	}, <-tokens.forms)
+
+	credentials = &ClientCredentials{TokenURL: tokens.URL, ClientID: "app", ClientSecret: "s3cr3t", AuthInBody: true}
+	token, err := credentials.Token(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "t2", token.AccessToken)
+	form := <-tokens.forms
+	assert.Equal(t, "app", form["client_id"])
+	assert.Equal(t, "s3cr3t", form["client_secret"])
+	assert.Equal(t, ":", form["basic"])
+}
+
+func TestClientCredentialsRefreshBeforeExpiry(t *testing.T) {
+
+	tokens := newTokenServer(60, 0)
+	defer tokens.Close()
+
+	now := time.Date(2017, 7, 20, 10, 0, 0, 0, time.UTC)
+	credentials := &ClientCredentials{TokenURL: tokens.URL, ClientID: "app", ClientSecret: "s3cr3t"}
+	credentials.now = func() time.Time { return now }
+
+	token, err := credentials.Token(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "t1", token.AccessToken)
+	assert.Equal(t, now.Add(time.Minute), token.Expiry)
+
+	now = now.Add(49 * time.Second)
+	token, _ = credentials.Token(context.Background())
+	assert.Equal(t, "t1", token.AccessToken)
+
+	now = now.Add(2 * time.Second)
+	token, _ = credentials.Token(context.Background())
+	assert.Equal(t, "t2", token.AccessToken)
+}
+
+func TestClientCredentialsSingleRefreshInFlight(t *testing.T) {
+
+	tokens := newTokenServer(3600, 50*time.Millisecond)
+	defer tokens.Close()
+	ts := newProtectedServer(func(string) bool { return true })
+	defer ts.Close()
+
+	client := Client{URL: ts.URL, Authenticator: &ClientCredentials{TokenURL: tokens.URL, ClientID: "app", ClientSecret: "s3cr3t"}}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out := new(string)
+			assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/", nil, out, nil)))
+			assert.Equal(t, "t1", *out)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tokens.requests))
+}
+
+func TestClientCredentialsRetryOnUnauthorized(t *testing.T) {
+
+	tokens := newTokenServer(3600, 0)
+	defer tokens.Close()
+	ts := newProtectedServer(func(token string) bool { return token != "t1" })
+	defer ts.Close()
+
+	client := Client{URL: ts.URL, Authenticator: &ClientCredentials{TokenURL: tokens.URL, ClientID: "app", ClientSecret: "s3cr3t"}}
+	out := new(string)
+	api := NewBaseAPI(http.MethodPost, "/", "payload", out, nil)
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, "t2", *out)
+	assert.Equal(t, 2, api.Attempts())
+
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	api = NewBaseAPI(http.MethodGet, "/", nil, out, nil)
+	assert.True(t, errors.Is(client.Do(api), ErrUnauthorized))
+	assert.Equal(t, 2, api.Attempts())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&tokens.requests))
+}
+
+func TestClientCredentialsTokenError(t *testing.T) {
+
+	tokens := newTokenServer(3600, 0)
+	defer tokens.Close()
+
+	client := Client{URL: "http://localhost", Authenticator: &ClientCredentials{TokenURL: tokens.URL, ClientID: "app", ClientSecret: "wrong"}}
+	err := client.Do(NewBaseAPI(http.MethodGet, "/", nil, nil, nil))
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	var httpErr *HTTPError
+	assert.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, &TokenError{Code: "invalid_client", Description: "unknown client"}, httpErr.ErrorObject)
+	assert.Equal(t, "OAuth2 error: invalid_client: unknown client", httpErr.ErrorObject.(error).Error())
+}
+
+func TestClientCredentialsFormEncodedToken(t *testing.T) {
+
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+		w.Write([]byte("access_token=form&token_type=bearer&scope=repo"))
+	}))
+	defer tokens.Close()
+
+	credentials := &ClientCredentials{TokenURL: tokens.URL, ClientID: "app", ClientSecret: "s3cr3t"}
+	token, err := credentials.Token(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, &Token{AccessToken: "form", TokenType: "bearer", Scope: "repo"}, token)
+}
+
+func TestClientCredentialsRefreshOutlivesCancelledCaller(t *testing.T) {
+
+	tokens := newTokenServer(3600, 100*time.Millisecond)
+	defer tokens.Close()
+
+	credentials := &ClientCredentials{TokenURL: tokens.URL, ClientID: "app", ClientSecret: "s3cr3t"}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	first := make(chan error)
+	go func() {
+		_, err := credentials.Token(ctx)
+		first <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	token, err := credentials.Token(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "t1", token.AccessToken)
+	assert.True(t, errors.Is(<-first, context.DeadlineExceeded))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tokens.requests))
+}
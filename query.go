@@ -0,0 +1,506 @@
+package rest
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryEncoder - Implemented by types that encode themselves as query
+// parameters; key is the name given to the field by its url tag.
+type QueryEncoder interface {
+	EncodeQuery(key string, values url.Values) error
+}
+
+var (
+	queryEncoderType    = reflect.TypeOf((*QueryEncoder)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// urlTag - The parsed url tag of a struct field:
+//
+//	url:"name,omitempty,comma"
+//
+// Supported options are omitempty (skip zero values), comma (join slice
+// elements with commas instead of repeating the parameter), unix and
+// unixmilli (encode a time.Time as a Unix timestamp) and int (encode a bool
+// as 1 or 0). A time.Time is encoded as RFC 3339 unless a layout is given
+// with a separate layout:"2006-01-02" tag. A url:"-" field is skipped.
+type urlTag struct {
+	name      string
+	omitEmpty bool
+	comma     bool
+	unix      bool
+	unixMilli bool
+	intBool   bool
+	layout    string
+}
+
+func parseURLTag(field reflect.StructField) (urlTag, bool) {
+	tagValue := field.Tag.Get("url")
+	if tagValue == "-" {
+		return urlTag{}, false
+	}
+	options := strings.Split(tagValue, ",")
+	tag := urlTag{name: options[0], layout: field.Tag.Get("layout")}
+	for _, option := range options[1:] {
+		switch option {
+		case "omitempty":
+			tag.omitEmpty = true
+		case "comma":
+			tag.comma = true
+		case "unix":
+			tag.unix = true
+		case "unixmilli":
+			tag.unixMilli = true
+		case "int":
+			tag.intBool = true
+		}
+	}
+	return tag, true
+}
+
+// EncodeQuery - Encodes a struct, or a pointer to one, as query parameters
+// according to the url tags of its fields (see urlTag). Fields of embedded
+// structs are encoded as if they were fields of the outer struct; fields of
+// nested structs are named parent[child].
+func EncodeQuery(v interface{}) (url.Values, error) {
+	values := make(url.Values)
+	if v == nil {
+		return values, nil
+	}
+	if encoder, ok := v.(QueryEncoder); ok {
+		return values, encoder.EncodeQuery("", values)
+	}
+
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return values, nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("query object expected to be a struct, got %T", v)
+	}
+	return values, encodeStruct(values, "", value)
+}
+
+func encodeStruct(values url.Values, scope string, value reflect.Value) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag, ok := parseURLTag(field)
+		if !ok {
+			continue
+		}
+		fieldValue := value.Field(i)
+
+		if field.Anonymous && tag.name == "" {
+			embedded := fieldValue
+			if embedded.Kind() == reflect.Ptr {
+				if embedded.IsNil() {
+					continue
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct && !implementsQueryEncoder(embedded) {
+				if err := encodeStruct(values, scope, embedded); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := tag.name
+		if name == "" {
+			name = field.Name
+		}
+		if scope != "" {
+			name = scope + "[" + name + "]"
+		}
+		if err := encodeField(values, name, fieldValue, tag); err != nil {
+			return fmt.Errorf("query parameter %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func implementsQueryEncoder(value reflect.Value) bool {
+	return value.Type().Implements(queryEncoderType) ||
+		(value.CanAddr() && value.Addr().Type().Implements(queryEncoderType))
+}
+
+func encodeField(values url.Values, name string, value reflect.Value, tag urlTag) error {
+	if tag.omitEmpty && isEmptyValue(value) {
+		return nil
+	}
+
+	if value.Type().Implements(queryEncoderType) {
+		if value.Kind() == reflect.Ptr && value.IsNil() {
+			return nil
+		}
+		return value.Interface().(QueryEncoder).EncodeQuery(name, values)
+	}
+	if value.CanAddr() && value.Addr().Type().Implements(queryEncoderType) {
+		return value.Addr().Interface().(QueryEncoder).EncodeQuery(name, values)
+	}
+
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			values.Add(name, "")
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	switch {
+	case value.Type() == timeType:
+		values.Add(name, formatTime(value.Interface().(time.Time), tag))
+		return nil
+
+	case value.Kind() == reflect.Slice || value.Kind() == reflect.Array:
+		if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8 {
+			values.Add(name, string(value.Bytes()))
+			return nil
+		}
+		elements := make([]string, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			element, err := formatScalar(value.Index(i), tag)
+			if err != nil {
+				return err
+			}
+			elements = append(elements, element)
+		}
+		if tag.comma {
+			values.Add(name, strings.Join(elements, ","))
+		} else {
+			values[name] = append(values[name], elements...)
+		}
+		return nil
+
+	case value.Kind() == reflect.Struct:
+		return encodeStruct(values, name, value)
+	}
+
+	formatted, err := formatScalar(value, tag)
+	if err != nil {
+		return err
+	}
+	values.Add(name, formatted)
+	return nil
+}
+
+func formatScalar(value reflect.Value, tag urlTag) (string, error) {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return "", nil
+		}
+		value = value.Elem()
+	}
+	if value.Type() == timeType {
+		return formatTime(value.Interface().(time.Time), tag), nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Bool:
+		if tag.intBool {
+			if value.Bool() {
+				return "1", nil
+			}
+			return "0", nil
+		}
+		return strconv.FormatBool(value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 64), nil
+	}
+	if stringer, ok := value.Interface().(fmt.Stringer); ok {
+		return stringer.String(), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", value.Type())
+}
+
+func formatTime(t time.Time, tag urlTag) string {
+	switch {
+	case tag.unix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case tag.unixMilli:
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	case tag.layout != "":
+		return t.Format(tag.layout)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// isEmptyValue - Tells whether value is the zero value for omitempty.
+func isEmptyValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Bool:
+		return !value.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return value.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return value.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return value.IsNil()
+	}
+	if value.Type() == timeType {
+		return value.Interface().(time.Time).IsZero()
+	}
+	return false
+}
+
+// DecodeQuery - Decodes query parameters, or a form-encoded payload, into
+// the struct v points to, the reverse of EncodeQuery. Parameters without a
+// matching field are ignored; pointers are allocated as needed and
+// encoding.TextUnmarshaler types decode themselves.
+func DecodeQuery(values url.Values, v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("query object expected to be a non-nil pointer to a struct, got %T", v)
+	}
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("query object expected to be a non-nil pointer to a struct, got %T", v)
+	}
+	return decodeStruct(values, "", value)
+}
+
+func decodeStruct(values url.Values, scope string, value reflect.Value) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag, ok := parseURLTag(field)
+		if !ok {
+			continue
+		}
+		fieldValue := value.Field(i)
+
+		if field.Anonymous && tag.name == "" {
+			embeddedType := field.Type
+			if embeddedType.Kind() == reflect.Ptr {
+				embeddedType = embeddedType.Elem()
+			}
+			if embeddedType.Kind() == reflect.Struct && !decodesText(embeddedType) {
+				if fieldValue.Kind() == reflect.Ptr {
+					if !hasScope(values, scope) || !fieldValue.CanSet() {
+						continue
+					}
+					if fieldValue.IsNil() {
+						fieldValue.Set(reflect.New(embeddedType))
+					}
+					fieldValue = fieldValue.Elem()
+				}
+				if err := decodeStruct(values, scope, fieldValue); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := tag.name
+		if name == "" {
+			name = field.Name
+		}
+		if scope != "" {
+			name = scope + "[" + name + "]"
+		}
+		if err := decodeField(values, name, fieldValue, tag); err != nil {
+			return fmt.Errorf("query parameter %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// hasScope - Tells whether values holds any parameter of the given scope,
+// so that nil pointers are only allocated for structs with something to
+// decode.
+func hasScope(values url.Values, scope string) bool {
+	if scope == "" {
+		return len(values) > 0
+	}
+	for key := range values {
+		if strings.HasPrefix(key, scope+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+func decodesText(valueType reflect.Type) bool {
+	return valueType.Implements(textUnmarshalerType) || reflect.PtrTo(valueType).Implements(textUnmarshalerType)
+}
+
+func decodeField(values url.Values, name string, value reflect.Value, tag urlTag) error {
+	valueType := value.Type()
+	for valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+
+	if valueType.Kind() == reflect.Struct && valueType != timeType && !decodesText(valueType) {
+		if !hasScope(values, name) {
+			return nil
+		}
+		return decodeStruct(values, name, allocate(value))
+	}
+
+	parameter, ok := values[name]
+	if !ok || len(parameter) == 0 {
+		return nil
+	}
+	value = allocate(value)
+
+	if value.Kind() == reflect.Slice && value.Type().Elem().Kind() != reflect.Uint8 && !decodesText(value.Type()) {
+		elements := parameter
+		if tag.comma {
+			elements = strings.Split(parameter[0], ",")
+		}
+		slice := reflect.MakeSlice(value.Type(), len(elements), len(elements))
+		for i, element := range elements {
+			if err := parseScalar(allocate(slice.Index(i)), element, tag); err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+		return nil
+	}
+	return parseScalar(value, parameter[0], tag)
+}
+
+// allocate - Follows value through its pointers, allocating the nil ones.
+func allocate(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		value = value.Elem()
+	}
+	return value
+}
+
+func parseScalar(value reflect.Value, s string, tag urlTag) error {
+	if value.CanAddr() {
+		if unmarshaler, ok := value.Addr().Interface().(encoding.TextUnmarshaler); ok && value.Type() != timeType {
+			return unmarshaler.UnmarshalText([]byte(s))
+		}
+	}
+	if value.Type() == timeType {
+		t, err := parseTime(s, tag)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", value.Type())
+		}
+		value.SetBytes([]byte(s))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
+
+func parseTime(s string, tag urlTag) (time.Time, error) {
+	switch {
+	case tag.unix:
+		seconds, err := strconv.ParseInt(s, 10, 64)
+		return time.Unix(seconds, 0), err
+	case tag.unixMilli:
+		millis, err := strconv.ParseInt(s, 10, 64)
+		return time.Unix(0, millis*int64(time.Millisecond)), err
+	case tag.layout != "":
+		return time.Parse(tag.layout, s)
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// hasURLTags - Tells whether the struct type t, or one of the structs it
+// embeds or nests, declares url tags.
+func hasURLTags(t reflect.Type) bool {
+	return hasURLTagsSeen(t, map[reflect.Type]bool{})
+}
+
+func hasURLTagsSeen(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType || seen[t] {
+		return false
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if _, ok := field.Tag.Lookup("url"); ok {
+			return true
+		}
+		if hasURLTagsSeen(field.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package rest
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type Paging struct {
+	Page int `url:"page,omitempty"`
+	Size int `url:"size,omitempty"`
+}
+
+type Range struct {
+	From int
+	To   int
+}
+
+// EncodeQuery - Encodes the range as a single from-to parameter.
+func (r Range) EncodeQuery(key string, values url.Values) error {
+	values.Set(key, fmt.Sprintf("%d-%d", r.From, r.To))
+	return nil
+}
+
+type Filter struct {
+	Paging
+	Name      string    `url:"name"`
+	Empty     string    `url:"empty,omitempty"`
+	Tags      []string  `url:"tag"`
+	States    []string  `url:"states,comma"`
+	Enabled   *bool     `url:"enabled,omitempty"`
+	Strict    bool      `url:"strict,int"`
+	Since     time.Time `url:"since"`
+	Until     time.Time `url:"until,unix"`
+	Day       time.Time `url:"day" layout:"2006-01-02"`
+	Ratio     float64   `url:"ratio,omitempty"`
+	Sort      Sort      `url:"sort"`
+	Window    Range     `url:"window"`
+	Skipped   string    `url:"-"`
+	Untagged  uint
+	unexposed string
+}
+
+type Sort struct {
+	By    string `url:"by"`
+	Order string `url:"order,omitempty"`
+}
+
+func TestEncodeQuery(t *testing.T) {
+	enabled := false
+	day := time.Date(2017, 7, 20, 10, 30, 0, 0, time.UTC)
+	filter := &Filter{
+		Paging:    Paging{Page: 2},
+		Name:      "pool a",
+		Tags:      []string{"x", "y"},
+		States:    []string{"up", "draining"},
+		Enabled:   &enabled,
+		Strict:    true,
+		Since:     day,
+		Until:     day,
+		Day:       day,
+		Sort:      Sort{By: "name"},
+		Window:    Range{From: 1, To: 2},
+		Skipped:   "skipped",
+		Untagged:  7,
+		unexposed: "unexposed",
+	}
+
+	values, err := EncodeQuery(filter)
+	assert.Nil(t, err)
+	assert.Equal(t, url.Values{
+		"page":     {"2"},
+		"name":     {"pool a"},
+		"tag":      {"x", "y"},
+		"states":   {"up,draining"},
+		"enabled":  {"false"},
+		"strict":   {"1"},
+		"since":    {"2017-07-20T10:30:00Z"},
+		"until":    {"1500546600"},
+		"day":      {"2017-07-20"},
+		"sort[by]": {"name"},
+		"window":   {"1-2"},
+		"Untagged": {"7"},
+	}, values)
+
+	values, err = EncodeQuery(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(values))
+
+	_, err = EncodeQuery("not a struct")
+	assert.NotNil(t, err)
+
+	_, err = EncodeQuery(struct {
+		C chan int `url:"c"`
+	}{})
+	assert.NotNil(t, err)
+}
+
+func TestQueryObjectRequest(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "a=1&page=3&size=10", r.URL.RawQuery)
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	api := NewBaseAPI(http.MethodGet, "/items?a=1&page=1", nil, nil, nil)
+	api.SetQueryObject(Paging{Page: 2, Size: 10})
+	api.Query().Set("page", "3")
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, http.StatusOK, api.StatusCode())
+}
+
+func TestDecodeQuery(t *testing.T) {
+	day := time.Date(2017, 7, 20, 10, 30, 0, 0, time.UTC)
+	values := url.Values{
+		"page":     {"2"},
+		"name":     {"pool a"},
+		"tag":      {"x", "y"},
+		"states":   {"up,draining"},
+		"enabled":  {"false"},
+		"strict":   {"1"},
+		"since":    {"2017-07-20T10:30:00Z"},
+		"until":    {"1500546600"},
+		"day":      {"2017-07-20"},
+		"sort[by]": {"name"},
+		"Untagged": {"7"},
+		"Skipped":  {"ignored"},
+		"unknown":  {"ignored"},
+	}
+
+	filter := new(Filter)
+	assert.Nil(t, DecodeQuery(values, filter))
+	assert.Equal(t, 2, filter.Page)
+	assert.Equal(t, "pool a", filter.Name)
+	assert.Equal(t, []string{"x", "y"}, filter.Tags)
+	assert.Equal(t, []string{"up", "draining"}, filter.States)
+	assert.NotNil(t, filter.Enabled)
+	assert.False(t, *filter.Enabled)
+	assert.True(t, filter.Strict)
+	assert.Equal(t, day, filter.Since)
+	assert.True(t, day.Equal(filter.Until))
+	assert.Equal(t, "2017-07-20", filter.Day.Format("2006-01-02"))
+	assert.Equal(t, Sort{By: "name"}, filter.Sort)
+	assert.Equal(t, uint(7), filter.Untagged)
+	assert.Equal(t, "", filter.Skipped)
+
+	var nested struct {
+		Sort *Sort `url:"sort"`
+		None *Sort `url:"none"`
+	}
+	assert.Nil(t, DecodeQuery(values, &nested))
+	assert.Equal(t, &Sort{By: "name"}, nested.Sort)
+	assert.Nil(t, nested.None)
+
+	assert.NotNil(t, DecodeQuery(values, Filter{}))
+	assert.NotNil(t, DecodeQuery(url.Values{"page": {"two"}}, new(Paging)))
+}
@@ -0,0 +1,120 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit - Rate limiting state advertised by a server through the
+// Retry-After and X-RateLimit-* (or RateLimit-*) response headers.
+type RateLimit struct {
+	// Limit - Requests allowed in the current window, -1 when not advertised.
+	Limit int
+	// Remaining - Requests left in the current window, -1 when not advertised.
+	Remaining int
+	// Reset - When the current window ends, zero when not advertised.
+	Reset time.Time
+	// RetryAfter - Delay requested by Retry-After, zero when not advertised
+	// or when the server asked for an immediate retry.
+	RetryAfter time.Duration
+	// HasRetryAfter - Tells whether a valid Retry-After header was sent,
+	// telling a zero RetryAfter apart from a missing one.
+	HasRetryAfter bool
+}
+
+// resetEpochThreshold - X-RateLimit-Reset values above this are Unix
+// timestamps, smaller ones are a number of seconds from now.
+const resetEpochThreshold = 1000000000
+
+// parseRateLimit - Extracts the rate limiting headers of a response, returning
+// nil when there are none.
+func parseRateLimit(header http.Header, now time.Time) *RateLimit {
+	rateLimit := &RateLimit{Limit: -1, Remaining: -1}
+	found := false
+
+	if value := rateLimitHeader(header, "Limit"); value != "" {
+		if limit, err := strconv.Atoi(value); err == nil {
+			rateLimit.Limit = limit
+			found = true
+		}
+	}
+	if value := rateLimitHeader(header, "Remaining"); value != "" {
+		if remaining, err := strconv.Atoi(value); err == nil {
+			rateLimit.Remaining = remaining
+			found = true
+		}
+	}
+	if value := rateLimitHeader(header, "Reset"); value != "" {
+		if reset, err := strconv.ParseInt(value, 10, 64); err == nil {
+			if reset > resetEpochThreshold {
+				rateLimit.Reset = time.Unix(reset, 0)
+			} else {
+				rateLimit.Reset = now.Add(time.Duration(reset) * time.Second)
+			}
+			found = true
+		}
+	}
+	if retryAfter, ok := parseRetryAfter(header.Get("Retry-After"), now); ok {
+		rateLimit.RetryAfter = retryAfter
+		rateLimit.HasRetryAfter = true
+		found = true
+	}
+
+	if !found {
+		return nil
+	}
+	return rateLimit
+}
+
+// rateLimitHeader - Returns the X-RateLimit-<name> header, falling back to
+// the RateLimit-<name> form of the IETF draft.
+func rateLimitHeader(header http.Header, name string) string {
+	if value := header.Get("X-RateLimit-" + name); value != "" {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(header.Get("RateLimit-" + name))
+}
+
+// parseRetryAfter - Parses a Retry-After value given either as a number of
+// seconds or as an HTTP-date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
+
+// Wait - Returns how long the server asked clients to wait before sending
+// another request: the Retry-After delay or, once no request remains in the
+// window, the time left until it resets.
+func (r *RateLimit) Wait(now time.Time) (time.Duration, bool) {
+	if r == nil {
+		return 0, false
+	}
+	if r.HasRetryAfter || r.RetryAfter > 0 {
+		return r.RetryAfter, true
+	}
+	if r.Remaining == 0 && !r.Reset.IsZero() {
+		if wait := r.Reset.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,159 @@
+package rest
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2017, 7, 20, 10, 0, 0, 0, time.UTC)
+
+	delay, ok := parseRetryAfter("120", now)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, delay)
+
+	delay, ok = parseRetryAfter("Thu, 20 Jul 2017 10:00:30 GMT", now)
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, delay)
+
+	delay, ok = parseRetryAfter("Thu, 20 Jul 2017 09:00:00 GMT", now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), delay)
+
+	_, ok = parseRetryAfter("", now)
+	assert.False(t, ok)
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(t, ok)
+	_, ok = parseRetryAfter("-1", now)
+	assert.False(t, ok)
+}
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Date(2017, 7, 20, 10, 0, 0, 0, time.UTC)
+
+	assert.Nil(t, parseRateLimit(http.Header{}, now))
+
+	header := http.Header{}
+	header.Set("X-RateLimit-Limit", "100")
+	header.Set("X-RateLimit-Remaining", "0")
+	header.Set("X-RateLimit-Reset", "1500544860")
+	rateLimit := parseRateLimit(header, now)
+	assert.Equal(t, 100, rateLimit.Limit)
+	assert.Equal(t, 0, rateLimit.Remaining)
+	assert.Equal(t, time.Unix(1500544860, 0), rateLimit.Reset)
+	wait, ok := rateLimit.Wait(now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, wait)
+
+	header = http.Header{}
+	header.Set("RateLimit-Remaining", "5")
+	header.Set("RateLimit-Reset", "30")
+	rateLimit = parseRateLimit(header, now)
+	assert.Equal(t, -1, rateLimit.Limit)
+	assert.Equal(t, 5, rateLimit.Remaining)
+	assert.Equal(t, now.Add(30*time.Second), rateLimit.Reset)
+	_, ok = rateLimit.Wait(now)
+	assert.False(t, ok)
+
+	header = http.Header{}
+	header.Set("Retry-After", "3")
+	rateLimit = parseRateLimit(header, now)
+	wait, ok = rateLimit.Wait(now)
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, wait)
+
+	for _, retryAfter := range []string{"0", "Thu, 20 Jul 2017 09:00:00 GMT"} {
+		header = http.Header{}
+		header.Set("Retry-After", retryAfter)
+		rateLimit = parseRateLimit(header, now)
+		assert.True(t, rateLimit.HasRetryAfter)
+		assert.Equal(t, time.Duration(0), rateLimit.RetryAfter)
+		wait, ok = rateLimit.Wait(now)
+		assert.True(t, ok)
+		assert.Equal(t, time.Duration(0), wait)
+	}
+}
+
+func TestRateLimitExposedOnBaseAPI(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", "59")
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	api := NewBaseAPI(http.MethodGet, "/", nil, nil, nil)
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, 60, api.RateLimit().Limit)
+	assert.Equal(t, 59, api.RateLimit().Remaining)
+}
+
+func TestRetryAfterIsHonoured(t *testing.T) {
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+	}))
+	defer ts.Close()
+
+	policy := &RetryPolicy{MaxAttempts: 2, RetryAfterBudget: 2 * time.Second}
+	client := Client{URL: ts.URL, RetryPolicy: policy}
+	api := NewBaseAPI(http.MethodGet, "/", nil, nil, nil)
+
+	start := time.Now()
+	assert.Nil(t, client.Do(api))
+	assert.True(t, time.Since(start) >= time.Second)
+	assert.Equal(t, 2, api.Attempts())
+	assert.Equal(t, http.StatusOK, api.StatusCode())
+}
+
+func TestRetryAfterZeroRetriesImmediately(t *testing.T) {
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	policy := &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Hour, RetryAfterBudget: time.Second}
+	client := Client{URL: ts.URL, RetryPolicy: policy}
+	api := NewBaseAPI(http.MethodGet, "/", nil, nil, nil)
+	start := time.Now()
+	assert.Nil(t, client.Do(api))
+	assert.True(t, time.Since(start) < time.Minute)
+	assert.Equal(t, 2, api.Attempts())
+}
+
+func TestRetryAfterBeyondBudget(t *testing.T) {
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	policy := &RetryPolicy{MaxAttempts: 3, RetryAfterBudget: time.Second}
+	client := Client{URL: ts.URL, RetryPolicy: policy}
+	api := NewBaseAPI(http.MethodGet, "/", nil, nil, nil)
+
+	start := time.Now()
+	client.Do(api)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, http.StatusServiceUnavailable, api.StatusCode())
+	assert.Equal(t, time.Hour, api.RateLimit().RetryAfter)
+}
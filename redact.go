@@ -0,0 +1,305 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"github.com/sky-uk/go-rest-api/contenttype"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultMask - Replacement for redacted values when Redactor.Mask is empty.
+const DefaultMask = "***"
+
+// Redactor - Masks secrets in the headers, URLs and payloads written to the
+// debug traces of a Client.
+type Redactor struct {
+	// Headers - Names of the headers whose values are masked, matched
+	// case-insensitively.
+	Headers []string
+
+	// Fields - JSON/XML and form fields and query parameters whose values are masked,
+	// matched case-insensitively. A bare name ("password") matches the field
+	// at any depth, a dotted path ("credentials.password") only matches from
+	// the document root. XML attributes are addressed as element.attribute.
+	Fields []string
+
+	// Mask - Replacement for the masked values, DefaultMask when empty.
+	Mask string
+
+	// queryParams - Query parameters also masked, for a single call.
+	queryParams []string
+}
+
+// DefaultRedactor - Redactor used when Client.Redactor is nil. Set
+// Client.Redactor to an empty &Redactor{} to disable redaction.
+var DefaultRedactor = &Redactor{
+	Headers: []string{
+		"Authorization",
+		"Proxy-Authorization",
+		"Cookie",
+		"Set-Cookie",
+		"X-Api-Key",
+		"X-Auth-Token",
+		"X-Csrf-Token",
+		"X-Xsrf-Token",
+		"X-Amz-Security-Token",
+	},
+	Fields: []string{
+		"password",
+		"passwd",
+		"secret",
+		"client_secret",
+		"token",
+		"access_token",
+		"refresh_token",
+		"id_token",
+		"api_key",
+		"apikey",
+	},
+}
+
+// redactor - Returns the Redactor of restClient, DefaultRedactor when unset.
+func (restClient *Client) redactor() *Redactor {
+	if restClient.Redactor != nil {
+		return restClient.Redactor
+	}
+	return DefaultRedactor
+}
+
+func (r *Redactor) mask() string {
+	if r.Mask != "" {
+		return r.Mask
+	}
+	return DefaultMask
+}
+
+// Header - Returns a copy of header with the configured headers masked.
+func (r *Redactor) Header(header http.Header) http.Header {
+	redacted := make(http.Header, len(header))
+	for name, values := range header {
+		if containsFold(r.Headers, name) {
+			masked := make([]string, len(values))
+			for i := range masked {
+				masked[i] = r.mask()
+			}
+			values = masked
+		}
+		redacted[name] = values
+	}
+	return redacted
+}
+
+// URL - Returns rawURL with its password and the configured query
+// parameters masked.
+func (r *Redactor) URL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), r.mask())
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		masked := false
+		for name := range query {
+			if r.matchField(nil, name) || containsFold(r.queryParams, name) {
+				query[name] = []string{r.mask()}
+				masked = true
+			}
+		}
+		if masked {
+			u.RawQuery = query.Encode()
+		}
+	}
+	return u.String()
+}
+
+// Error - Returns the message of err with the URL of the *url.Error it
+// wraps, if any, redacted like URL does.
+func (r *Redactor) Error(err error) string {
+	message := err.Error()
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.URL != "" {
+		message = strings.Replace(message, urlErr.URL, r.URL(urlErr.URL), -1)
+	}
+	return message
+}
+
+// urlError - Returns err, when it is a *url.Error, with its URL redacted
+// like URL does.
+func (r *Redactor) urlError(err error) error {
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return err
+	}
+	redacted := *urlErr
+	redacted.URL = r.URL(urlErr.URL)
+	return &redacted
+}
+
+// Payload - Returns payload with the configured fields masked, when its
+// Content-Type is JSON or XML (suffixed types such as application/hal+json
+// included).
+func (r *Redactor) Payload(contentType string, payload []byte) []byte {
+	if len(r.Fields) == 0 || len(payload) == 0 {
+		return payload
+	}
+	syntax := contenttype.GetType(contentType)
+	if mediaType, err := contenttype.Parse(contentType); err == nil {
+		syntax = mediaType.Syntax()
+		if mediaType.Essence() == "application/x-www-form-urlencoded" {
+			syntax = "form"
+		}
+	}
+	switch syntax {
+	case "form":
+		return r.formPayload(payload)
+	case "json":
+		return r.jsonPayload(payload)
+	case "xml":
+		return r.xmlPayload(payload)
+	}
+	return payload
+}
+
+func (r *Redactor) formPayload(payload []byte) []byte {
+	values, err := url.ParseQuery(string(payload))
+	if err != nil {
+		return payload
+	}
+	for key := range values {
+		if r.matchField(nil, key) {
+			values[key] = []string{r.mask()}
+		}
+	}
+	return []byte(values.Encode())
+}
+
+func (r *Redactor) jsonPayload(payload []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return payload
+	}
+	redacted, err := json.Marshal(r.jsonValue(nil, document))
+	if err != nil {
+		return payload
+	}
+	return redacted
+}
+
+func (r *Redactor) jsonValue(path []string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			fieldPath := append(path[:len(path):len(path)], key)
+			if r.matchField(path, key) {
+				v[key] = r.mask()
+			} else {
+				v[key] = r.jsonValue(fieldPath, field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.jsonValue(path, item)
+		}
+	}
+	return value
+}
+
+func (r *Redactor) xmlPayload(payload []byte) []byte {
+	decoder := xml.NewDecoder(bytes.NewReader(payload))
+	var out bytes.Buffer
+	encoder := xml.NewEncoder(&out)
+
+	var path []string
+	maskedDepth := 0
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return payload
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if maskedDepth == 0 && r.matchField(path, t.Name.Local) {
+				maskedDepth = len(path) + 1
+			}
+			path = append(path, t.Name.Local)
+			t = t.Copy()
+			t.Name = prefixedName(t.Name)
+			for i, attr := range t.Attr {
+				if r.matchField(path, attr.Name.Local) {
+					t.Attr[i].Value = r.mask()
+				}
+				t.Attr[i].Name = prefixedName(attr.Name)
+			}
+			token = t
+		case xml.EndElement:
+			token = xml.EndElement{Name: prefixedName(t.Name)}
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+			if maskedDepth > len(path) {
+				maskedDepth = 0
+			}
+		case xml.CharData:
+			if maskedDepth > 0 {
+				if len(bytes.TrimSpace(t)) == 0 {
+					continue
+				}
+				token = xml.CharData(r.mask())
+			}
+		}
+		if err := encoder.EncodeToken(token); err != nil {
+			return payload
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		return payload
+	}
+	return out.Bytes()
+}
+
+// prefixedName - Returns name as read by RawToken, its Space holding the
+// prefix rather than a namespace URL, as a local name written as is.
+func prefixedName(name xml.Name) xml.Name {
+	if name.Space == "" {
+		return name
+	}
+	return xml.Name{Local: name.Space + ":" + name.Local}
+}
+
+// matchField - Tells whether the field name, found under parent, is one of
+// the configured fields.
+func (r *Redactor) matchField(parent []string, name string) bool {
+	fullPath := strings.Join(append(parent[:len(parent):len(parent)], name), ".")
+	for _, field := range r.Fields {
+		if strings.Contains(field, ".") {
+			if strings.EqualFold(field, fullPath) {
+				return true
+			}
+		} else if strings.EqualFold(field, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
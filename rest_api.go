@@ -1,5 +1,12 @@
 package rest
 
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
 // BaseAPI  - Base API struct.
 type BaseAPI struct {
 	method         string
@@ -10,9 +17,32 @@ type BaseAPI struct {
 	statusCode     int
 	rawResponse    []byte
 	err            error
+	attempts       int
+	rateLimit      *RateLimit
+	header         http.Header
+	query          url.Values
+	contentType    string
+	pathParams     map[string]string
+	queryObject    interface{}
+	responseHeader http.Header
+	trailer        http.Header
+	finalURL       string
+	proto          string
+	contentLength  int64
+	duration       time.Duration
+
+	requestContentLength int64
+	multipart            *Multipart
+	authenticator        Authenticator
 }
 
 // NewBaseAPI - Returns a new object of the BaseAPI.
+// The responseObject is usually a pointer the payload is decoded into. It can
+// also be an io.Writer the payload is copied to, or an *io.ReadCloser that
+// receives the response body, to be read and closed by the caller; neither
+// buffers the payload in memory.
+// The requestObject is marshalled with the codec of the request Content-Type,
+// unless it is an io.Reader, which is streamed as the payload as it is.
 func NewBaseAPI(
 	method string,
 	endpoint string,
@@ -20,7 +50,15 @@ func NewBaseAPI(
 	responseObject interface{},
 	errorObject interface{},
 ) *BaseAPI {
-	return &BaseAPI{method, endpoint, requestObject, responseObject, errorObject, 0, nil, nil}
+	return &BaseAPI{
+		method:         method,
+		endpoint:       endpoint,
+		requestObject:  requestObject,
+		responseObject: responseObject,
+		errorObject:    errorObject,
+
+		requestContentLength: -1,
+	}
 }
 
 // RequestObject - Returns the request object of the BaseAPI
@@ -43,36 +81,222 @@ func (b *BaseAPI) Method() string {
 	return b.method
 }
 
-// Endpoint - Returns the Endpoint url string.
+// Endpoint - Returns the Endpoint url string, possibly a template with
+// {name} path parameters.
 func (b *BaseAPI) Endpoint() string {
 	return b.endpoint
 }
 
+// Header - Returns the headers sent with this request only, on top of (and
+// replacing) the Client headers. The returned header can be modified.
+func (b *BaseAPI) Header() http.Header {
+	if b.header == nil {
+		b.header = make(http.Header)
+	}
+	return b.header
+}
+
+// Query - Returns the query parameters added to the endpoint URL. The
+// returned values can be modified.
+func (b *BaseAPI) Query() url.Values {
+	if b.query == nil {
+		b.query = make(url.Values)
+	}
+	return b.query
+}
+
+// QueryObject - Returns the struct encoded as query parameters.
+func (b *BaseAPI) QueryObject() interface{} {
+	return b.queryObject
+}
+
+// RequestContentLength - Returns the length of an io.Reader request object,
+// -1 when unknown.
+func (b *BaseAPI) RequestContentLength() int64 {
+	return b.requestContentLength
+}
+
+// Multipart - Returns the multipart/form-data payload, nil when not set.
+func (b *BaseAPI) Multipart() *Multipart {
+	return b.multipart
+}
+
+// Authenticator - Returns the Authenticator set for this call, nil when the
+// one of the Client applies.
+func (b *BaseAPI) Authenticator() Authenticator {
+	return b.authenticator
+}
+
+// ContentType - Returns the Content-Type of the request payload, "" when the
+// Client headers decide.
+func (b *BaseAPI) ContentType() string {
+	return b.contentType
+}
+
+// PathParams - Returns the values of the {name} parameters of the endpoint
+// template.
+func (b *BaseAPI) PathParams() map[string]string {
+	return b.pathParams
+}
+
 // StatusCode - Returns the status code of the api.
 func (b *BaseAPI) StatusCode() int {
 	return b.statusCode
 }
 
+// ResponseHeader - Returns the headers of the response.
+func (b *BaseAPI) ResponseHeader() http.Header {
+	return b.responseHeader
+}
+
+// Trailer - Returns the trailers of the response.
+func (b *BaseAPI) Trailer() http.Header {
+	return b.trailer
+}
+
+// FinalURL - Returns the URL of the response, after redirects.
+func (b *BaseAPI) FinalURL() string {
+	return b.finalURL
+}
+
+// Proto - Returns the protocol of the response, e.g. "HTTP/1.1".
+func (b *BaseAPI) Proto() string {
+	return b.proto
+}
+
+// ContentLength - Returns the length of the response payload: its
+// Content-Length, or the number of bytes read when it was not sent, or -1
+// when the unread body was handed to the caller.
+func (b *BaseAPI) ContentLength() int64 {
+	return b.contentLength
+}
+
+// Duration - Returns how long the call took, retries included.
+func (b *BaseAPI) Duration() time.Duration {
+	return b.duration
+}
+
 // RawResponse - Returns the rawResponse object as byte type.
 func (b *BaseAPI) RawResponse() []byte {
 	return b.rawResponse
 }
 
+// Attempts - Returns the number of times the request was sent.
+func (b *BaseAPI) Attempts() int {
+	return b.attempts
+}
+
+// RateLimit - Returns the rate limiting state advertised by the last
+// response, or nil when it carried no rate limiting headers.
+func (b *BaseAPI) RateLimit() *RateLimit {
+	return b.rateLimit
+}
+
 // Error - Returns the err the api.
 func (b *BaseAPI) Error() error {
 	return b.err
 }
 
+// SetHeader - Sets a header sent with this request only.
+func (b *BaseAPI) SetHeader(name string, value string) {
+	b.Header().Set(name, value)
+}
+
+// SetQuery - Sets the query parameters added to the endpoint URL.
+func (b *BaseAPI) SetQuery(query url.Values) {
+	b.query = query
+}
+
+// SetQueryObject - Sets a struct, annotated with url tags, encoded as query
+// parameters with EncodeQuery. Parameters set with Query() take precedence.
+func (b *BaseAPI) SetQueryObject(queryObject interface{}) {
+	b.queryObject = queryObject
+}
+
+// SetRequestContentLength - Sets the length of an io.Reader request object,
+// so that it is sent with a Content-Length rather than chunked. It is found
+// by seeking when the reader is an io.Seeker.
+func (b *BaseAPI) SetRequestContentLength(length int64) {
+	b.requestContentLength = length
+}
+
+// SetMultipart - Sets a multipart/form-data payload, sent in place of the
+// request object with the Content-Type holding its boundary.
+func (b *BaseAPI) SetMultipart(m *Multipart) {
+	b.multipart = m
+}
+
+// SetAuthenticator - Sets the Authenticator of this call, used instead of
+// the one of the Client.
+func (b *BaseAPI) SetAuthenticator(authenticator Authenticator) {
+	b.authenticator = authenticator
+}
+
+// SetContentType - Sets the Content-Type of the request payload, overriding
+// the Content-Type of the Client headers.
+func (b *BaseAPI) SetContentType(contentType string) {
+	b.contentType = contentType
+}
+
+// SetPathParam - Sets the value of the {name} parameter of the endpoint
+// template, e.g. "/vservers/{name}". The value is percent-escaped.
+func (b *BaseAPI) SetPathParam(name string, value string) {
+	if b.pathParams == nil {
+		b.pathParams = make(map[string]string)
+	}
+	b.pathParams[name] = value
+}
+
 // SetStatusCode - Sets the statusCode from api object.
 func (b *BaseAPI) SetStatusCode(statusCode int) {
 	b.statusCode = statusCode
 }
 
+// SetResponseHeader - Sets the response headers on api object.
+func (b *BaseAPI) SetResponseHeader(header http.Header) {
+	b.responseHeader = header
+}
+
+// SetTrailer - Sets the response trailers on api object.
+func (b *BaseAPI) SetTrailer(trailer http.Header) {
+	b.trailer = trailer
+}
+
+// SetFinalURL - Sets the response URL on api object.
+func (b *BaseAPI) SetFinalURL(finalURL string) {
+	b.finalURL = finalURL
+}
+
+// SetProto - Sets the response protocol on api object.
+func (b *BaseAPI) SetProto(proto string) {
+	b.proto = proto
+}
+
+// SetContentLength - Sets the response payload length on api object.
+func (b *BaseAPI) SetContentLength(contentLength int64) {
+	b.contentLength = contentLength
+}
+
+// SetDuration - Sets the call duration on api object.
+func (b *BaseAPI) SetDuration(duration time.Duration) {
+	b.duration = duration
+}
+
 // SetRawResponse - Sets the rawResponse on api object.
 func (b *BaseAPI) SetRawResponse(rawResponse []byte) {
 	b.rawResponse = rawResponse
 }
 
+// SetAttempts - Sets the number of times the request was sent.
+func (b *BaseAPI) SetAttempts(attempts int) {
+	b.attempts = attempts
+}
+
+// SetRateLimit - Sets the rate limiting state on api object.
+func (b *BaseAPI) SetRateLimit(rateLimit *RateLimit) {
+	b.rateLimit = rateLimit
+}
+
 // SetError - Sets the err on api object.
 func (b *BaseAPI) SetError(err error) {
 	b.err = err
@@ -87,3 +311,13 @@ func (b *BaseAPI) SetResponseObject(res interface{}) {
 func (b *BaseAPI) SetErrorObject(res interface{}) {
 	b.errorObject = res
 }
+
+// isStreamTarget - Tells whether a response object receives the payload as
+// a stream instead of having it decoded.
+func isStreamTarget(responseObject interface{}) bool {
+	switch responseObject.(type) {
+	case *io.ReadCloser, io.Writer:
+		return true
+	}
+	return false
+}
@@ -5,7 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/url"
 	"testing"
+	"time"
 )
 
 // ReqError - Base Error structure
@@ -87,3 +90,42 @@ func TestReqError(t *testing.T) {
 	fmt.Printf("Error struct:\n%+v", errObj)
 	assert.Equal(t, "resource.validation_failed", errObj.Error.ErrorID)
 }
+
+func TestBaseApiHeadersAndQuery(t *testing.T) {
+	api := NewBaseAPI(http.MethodGet, "/items", nil, nil, nil)
+	assert.Equal(t, 0, len(api.Header()))
+	assert.Equal(t, 0, len(api.Query()))
+	assert.Equal(t, "", api.ContentType())
+
+	api.SetHeader("x-trace", "abc")
+	api.Header().Add("X-Trace", "def")
+	api.Query().Set("page", "2")
+	api.SetContentType("application/xml")
+
+	assert.Equal(t, []string{"abc", "def"}, api.Header()["X-Trace"])
+	assert.Equal(t, "2", api.Query().Get("page"))
+	assert.Equal(t, "application/xml", api.ContentType())
+
+	api.SetQuery(url.Values{"size": []string{"10"}})
+	assert.Equal(t, "size=10", api.Query().Encode())
+}
+
+func TestBaseApiResponseMetadata(t *testing.T) {
+	api := NewBaseAPI(http.MethodGet, "/items", nil, nil, nil)
+	header := http.Header{"Etag": []string{`"v1"`}}
+	trailer := http.Header{"X-Checksum": []string{"abc"}}
+
+	api.SetResponseHeader(header)
+	api.SetTrailer(trailer)
+	api.SetFinalURL("http://host/items/")
+	api.SetProto("HTTP/2.0")
+	api.SetContentLength(42)
+	api.SetDuration(time.Second)
+
+	assert.Equal(t, header, api.ResponseHeader())
+	assert.Equal(t, trailer, api.Trailer())
+	assert.Equal(t, "http://host/items/", api.FinalURL())
+	assert.Equal(t, "HTTP/2.0", api.Proto())
+	assert.Equal(t, int64(42), api.ContentLength())
+	assert.Equal(t, time.Second, api.Duration())
+}
@@ -0,0 +1,217 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// DefaultRetryableStatusCodes - Status codes retried when
+// RetryPolicy.RetryableStatusCodes is nil.
+var DefaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// DefaultIdempotentMethods - Methods that may be retried when
+// RetryPolicy.IdempotentMethods is nil.
+var DefaultIdempotentMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodTrace,
+	http.MethodPut,
+	http.MethodDelete,
+}
+
+// RetryPolicy - Controls whether and when a failed call is sent again.
+// A request whose method is not idempotent is only retried when it carries
+// an Idempotency-Key (or X-Idempotency-Key) header, and a request whose
+// payload cannot be rewound is never retried.
+type RetryPolicy struct {
+	// MaxAttempts - Total number of attempts, the first one included.
+	// Values below 2 disable retries.
+	MaxAttempts int
+
+	// BaseDelay - Delay before the first retry, doubled for every further one.
+	BaseDelay time.Duration
+
+	// MaxDelay - Upper bound of the delay between two attempts (0 = no bound).
+	MaxDelay time.Duration
+
+	// Jitter - Fraction, between 0 and 1, of every delay that is randomised.
+	// 0 disables jitter, 1 gives "full jitter".
+	Jitter float64
+
+	// RetryableStatusCodes - Response status codes that trigger a retry.
+	// nil selects DefaultRetryableStatusCodes.
+	RetryableStatusCodes []int
+
+	// RetryNetworkErrors - Retry calls failing without a response because of
+	// a connection refused/reset, an unexpected EOF or a network timeout.
+	RetryNetworkErrors bool
+
+	// IsRetryableError - Optional replacement for the built-in network error
+	// classification used when RetryNetworkErrors is set.
+	IsRetryableError func(err error) bool
+
+	// IdempotentMethods - Methods that are safe to send more than once.
+	// nil selects DefaultIdempotentMethods.
+	IdempotentMethods []string
+
+	// RetryAfterBudget - Total time a call may spend waiting for the delays
+	// requested by the server through Retry-After or X-RateLimit-Reset.
+	// A requested delay that does not fit in what is left of the budget ends
+	// the retries. 0 ignores these headers and always uses the backoff.
+	RetryAfterBudget time.Duration
+}
+
+// DefaultRetryPolicy - Returns a policy making up to 3 attempts, with
+// exponential backoff from 100ms up to 5s and full jitter, retrying network
+// errors and DefaultRetryableStatusCodes.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:        3,
+		BaseDelay:          100 * time.Millisecond,
+		MaxDelay:           5 * time.Second,
+		Jitter:             1,
+		RetryNetworkErrors: true,
+	}
+}
+
+// canRetry - Tells whether another attempt of req may follow attempt.
+func (p *RetryPolicy) canRetry(req *http.Request, attempt int) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+	if !rewindable(req) {
+		return false
+	}
+	if req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != "" {
+		return true
+	}
+	methods := p.IdempotentMethods
+	if methods == nil {
+		methods = DefaultIdempotentMethods
+	}
+	for _, method := range methods {
+		if method == req.Method {
+			return true
+		}
+	}
+	return false
+}
+
+// retryableError - Tells whether a transport error is worth another attempt.
+func (p *RetryPolicy) retryableError(err error) bool {
+	if !p.RetryNetworkErrors {
+		return false
+	}
+	if p.IsRetryableError != nil {
+		return p.IsRetryableError(err)
+	}
+	return isTransientNetworkError(err)
+}
+
+// retryableStatus - Tells whether a response status code is worth another attempt.
+func (p *RetryPolicy) retryableStatus(statusCode int) bool {
+	codes := p.RetryableStatusCodes
+	if codes == nil {
+		codes = DefaultRetryableStatusCodes
+	}
+	for _, code := range codes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff - Returns the delay to wait before the given retry (1 for the
+// first retry, i.e. the second attempt).
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry; i++ {
+		delay *= 2
+		if delay <= 0 || (p.MaxDelay > 0 && delay >= p.MaxDelay) {
+			delay = p.MaxDelay
+			break
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 && delay > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		delay -= time.Duration(rand.Float64() * jitter * float64(delay))
+	}
+	return delay
+}
+
+// isTransientNetworkError - Recognises the network failures that usually go
+// away on their own.
+func isTransientNetworkError(err error) bool {
+	if errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// rewindable - Tells whether the payload of req can be produced again.
+func rewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewindRequest - Returns the request to send for the given attempt, with a
+// fresh copy of the payload for every attempt after the first one.
+func rewindRequest(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 1 || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	retryReq := req.Clone(req.Context())
+	retryReq.Body = body
+	return retryReq, nil
+}
+
+// discardResponse - Drains and closes the body of a response that is not
+// going to be handled, so that its connection can be reused.
+func discardResponse(res *http.Response) {
+	io.CopyN(ioutil.Discard, res.Body, 64*1024)
+	res.Body.Close()
+}
+
+// sleepContext - Waits for d, returning early with ctx.Err() when ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
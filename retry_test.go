@@ -0,0 +1,152 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:        3,
+		BaseDelay:          time.Millisecond,
+		MaxDelay:           5 * time.Millisecond,
+		RetryNetworkErrors: true,
+	}
+}
+
+func TestRetryOnRetryableStatus(t *testing.T) {
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, `{"field_1":"Foo","field_2":"Bar"}`, string(body))
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"field_1":"Fooo"}`))
+	}))
+	defer ts.Close()
+
+	headers := map[string]string{"Content-Type": "application/json"}
+	client := Client{URL: ts.URL, Headers: headers, RetryPolicy: testRetryPolicy()}
+
+	api := NewBaseAPI(http.MethodPut, "/", ReqBody{"Foo", "Bar"}, new(ReqBody), nil)
+	err := client.Do(api)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, api.Attempts())
+	assert.Equal(t, http.StatusOK, api.StatusCode())
+	assert.Equal(t, "Fooo", api.ResponseObject().(*ReqBody).FieldOne)
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL, RetryPolicy: testRetryPolicy()}
+	api := NewBaseAPI(http.MethodGet, "/", nil, nil, nil)
+	client.Do(api)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Equal(t, 3, api.Attempts())
+	assert.Equal(t, http.StatusBadGateway, api.StatusCode())
+}
+
+func TestNoRetryForNonIdempotentMethod(t *testing.T) {
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL, RetryPolicy: testRetryPolicy()}
+	api := NewBaseAPI(http.MethodPost, "/", nil, nil, nil)
+	client.Do(api)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, 1, api.Attempts())
+
+	client = Client{
+		URL:         ts.URL,
+		Headers:     map[string]string{"Idempotency-Key": "abc"},
+		RetryPolicy: testRetryPolicy(),
+	}
+	api = NewBaseAPI(http.MethodPost, "/", nil, nil, nil)
+	client.Do(api)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+	assert.Equal(t, 3, api.Attempts())
+}
+
+func TestRetryOnNetworkError(t *testing.T) {
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("pong"))
+	}))
+	defer ts.Close()
+
+	client := Client{URL: ts.URL, RetryPolicy: testRetryPolicy()}
+	api := NewBaseAPI(http.MethodGet, "/", nil, new(string), nil)
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, 2, api.Attempts())
+	assert.Equal(t, "pong", *api.ResponseObject().(*string))
+
+	policy := testRetryPolicy()
+	policy.RetryNetworkErrors = false
+	atomic.StoreInt32(&calls, 0)
+	client = Client{URL: ts.URL, RetryPolicy: policy}
+	api = NewBaseAPI(http.MethodGet, "/", nil, new(string), nil)
+	assert.NotNil(t, client.Do(api))
+	assert.Equal(t, 1, api.Attempts())
+}
+
+func TestRetryBackoffIsCancelledWithContext(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	policy := &RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour}
+	client := Client{URL: ts.URL, RetryPolicy: policy}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := client.DoContext(ctx, NewBaseAPI(http.MethodGet, "/", nil, nil, nil))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestRetryBackoff(t *testing.T) {
+
+	policy := &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 800*time.Millisecond, policy.backoff(4))
+	assert.Equal(t, time.Second, policy.backoff(5))
+	assert.Equal(t, time.Second, policy.backoff(100))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := policy.backoff(3)
+		assert.True(t, delay > 200*time.Millisecond && delay <= 400*time.Millisecond)
+	}
+}
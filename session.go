@@ -0,0 +1,256 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ErrNoLoginClient - Returned by a SessionAuth without a Client to send its
+// login call with.
+var ErrNoLoginClient = errors.New("no client set to send the login call")
+
+// SessionAuth - Authenticator for APIs handing out a session cookie, and
+// possibly a CSRF token, on a login call. The login call is sent with Client
+// when the first request is authenticated; its cookies are kept in Jar and
+// sent with every request, along with the CSRF token. When a response tells
+// the session expired (401 Unauthorized, 403 Forbidden or a redirect to the
+// login endpoint) the login call is sent again, and the request once more.
+type SessionAuth struct {
+	// Client - Client the login call is sent with, usually the one the
+	// SessionAuth authenticates; required. Its Authenticator is not used for the
+	// login call.
+	Client *Client
+
+	// LoginMethod - Method of the login call, POST when empty.
+	LoginMethod string
+
+	// LoginEndpoint - Endpoint of the login call, joined to the Client URL.
+	LoginEndpoint string
+
+	// LoginRequest - Payload of the login call, e.g. url.Values holding the
+	// credentials, sent as LoginContentType.
+	LoginRequest     interface{}
+	LoginContentType string
+
+	// CSRFHeader - Header carrying the CSRF token, e.g. X-XSRF-TOKEN. The
+	// token is read from the header of the same name of the login response
+	// or, when CSRFCookie is set, from that cookie.
+	CSRFHeader string
+	CSRFCookie string
+
+	// Jar - Optional store of the session cookies, an in-memory jar when
+	// nil.
+	Jar http.CookieJar
+
+	// IsExpired - Optional replacement of the default session expiry check.
+	IsExpired func(res *http.Response) bool
+
+	mu        sync.Mutex
+	loggedIn  bool
+	csrfToken string
+	login     *sessionLogin
+	jarOnce   sync.Once
+	jar       http.CookieJar
+}
+
+// sessionLogin - A login call in flight, waited for by the concurrent calls
+// needing a session.
+type sessionLogin struct {
+	done chan struct{}
+	err  error
+}
+
+// Authenticate - Sets the session cookies and the CSRF header, logging in
+// first when there is no session.
+func (s *SessionAuth) Authenticate(req *http.Request) error {
+	if s.Client == nil {
+		return ErrNoLoginClient
+	}
+	csrfToken, err := s.session(req.Context())
+	if err != nil {
+		return err
+	}
+
+	cookies := s.cookieJar().Cookies(req.URL)
+	sessionCookies := make(map[string]bool, len(cookies))
+	for _, cookie := range cookies {
+		sessionCookies[cookie.Name] = true
+	}
+	others := req.Cookies()
+	req.Header.Del("Cookie")
+	for _, cookie := range others {
+		if !sessionCookies[cookie.Name] {
+			req.AddCookie(cookie)
+		}
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	if s.CSRFHeader != "" && csrfToken != "" {
+		req.Header.Set(s.CSRFHeader, csrfToken)
+	}
+	return nil
+}
+
+// Reauthenticate - Keeps the cookies set by res and, when res tells the
+// session req was sent with expired, drops it so that the request is sent
+// again after logging in.
+func (s *SessionAuth) Reauthenticate(req *http.Request, res *http.Response) bool {
+	if cookies := res.Cookies(); len(cookies) > 0 {
+		s.cookieJar().SetCookies(req.URL, cookies)
+	}
+	if !s.expired(res) {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loggedIn && s.sentWithSession(req) {
+		s.loggedIn = false
+	}
+	return true
+}
+
+// Logout - Drops the session, so that the next request logs in again.
+func (s *SessionAuth) Logout() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loggedIn = false
+	s.csrfToken = ""
+}
+
+// session - Returns the CSRF token of the session, logging in first when
+// there is none.
+func (s *SessionAuth) session(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	if s.loggedIn {
+		csrfToken := s.csrfToken
+		s.mu.Unlock()
+		return csrfToken, nil
+	}
+	login := s.login
+	if login == nil {
+		login = &sessionLogin{done: make(chan struct{})}
+		s.login = login
+		go s.runLogin(ctx, login)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-login.done:
+		if login.err != nil {
+			return "", login.err
+		}
+		return s.session(ctx)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// runLogin - Sends the login call for login. The call is shared by all the
+// requests waiting for it, so that it is not cancelled with the context of
+// the request that started it; each request only stops waiting on its own.
+func (s *SessionAuth) runLogin(ctx context.Context, login *sessionLogin) {
+	ctx, cancel := detachedContext(ctx)
+	defer cancel()
+	csrfToken, err := s.doLogin(ctx)
+
+	s.mu.Lock()
+	s.login = nil
+	login.err = err
+	if err == nil {
+		s.loggedIn = true
+		s.csrfToken = csrfToken
+	}
+	s.mu.Unlock()
+	close(login.done)
+}
+
+// doLogin - Sends the login call, storing its cookies, and returns the CSRF
+// token it handed out.
+func (s *SessionAuth) doLogin(ctx context.Context) (string, error) {
+	method := s.LoginMethod
+	if method == "" {
+		method = http.MethodPost
+	}
+	api := NewBaseAPI(method, s.LoginEndpoint, s.LoginRequest, nil, nil)
+	api.SetContentType(s.LoginContentType)
+	api.SetAuthenticator(noAuthentication)
+	if err := s.Client.DoContext(ctx, api); err != nil {
+		return "", err
+	}
+
+	loginURL, err := url.Parse(api.FinalURL())
+	if err != nil {
+		return "", err
+	}
+	res := &http.Response{Header: api.ResponseHeader()}
+	cookies := res.Cookies()
+	s.cookieJar().SetCookies(loginURL, cookies)
+
+	if s.CSRFHeader != "" {
+		if csrfToken := api.ResponseHeader().Get(s.CSRFHeader); csrfToken != "" {
+			return csrfToken, nil
+		}
+	}
+	if s.CSRFCookie != "" {
+		for _, cookie := range cookies {
+			if cookie.Name == s.CSRFCookie {
+				return cookie.Value, nil
+			}
+		}
+		for _, cookie := range s.cookieJar().Cookies(loginURL) {
+			if cookie.Name == s.CSRFCookie {
+				return cookie.Value, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// expired - Tells whether res tells the session expired.
+func (s *SessionAuth) expired(res *http.Response) bool {
+	if s.IsExpired != nil {
+		return s.IsExpired(res)
+	}
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		return true
+	}
+	if res.Request == nil || res.Request.URL == nil || res.Request.Response == nil {
+		return false
+	}
+	loginPath := s.LoginEndpoint
+	if i := strings.IndexAny(loginPath, "?#"); i >= 0 {
+		loginPath = loginPath[:i]
+	}
+	if loginURL, err := url.Parse(loginPath); err == nil && loginURL.IsAbs() {
+		loginPath = loginURL.Path
+	}
+	return loginPath != "" && strings.HasSuffix(strings.TrimSuffix(res.Request.URL.Path, "/"), strings.TrimSuffix(loginPath, "/"))
+}
+
+// sentWithSession - Tells whether req carried the current session cookies,
+// so that a session renewed by a concurrent call is not dropped.
+func (s *SessionAuth) sentWithSession(req *http.Request) bool {
+	for _, cookie := range s.cookieJar().Cookies(req.URL) {
+		sent, err := req.Cookie(cookie.Name)
+		if err != nil || sent.Value != cookie.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *SessionAuth) cookieJar() http.CookieJar {
+	if s.Jar != nil {
+		return s.Jar
+	}
+	s.jarOnce.Do(func() {
+		s.jar, _ = cookiejar.New(nil)
+	})
+	return s.jar
+}
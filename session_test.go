@@ -0,0 +1,252 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// nsxServer - Appliance API handing out a JSESSIONID cookie and an
+// X-XSRF-TOKEN header on login, both required by /api/items.
+type nsxServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	logins   int
+	session  string
+	redirect bool
+}
+
+func newNSXServer() *nsxServer {
+	server := &nsxServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/session/create", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("j_username") != "nsxUser" || r.PostForm.Get("j_password") != "nsxPass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		server.mu.Lock()
+		server.logins++
+		server.session = fmt.Sprintf("s%d", server.logins)
+		session := server.session
+		server.mu.Unlock()
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: session, Path: "/"})
+		w.Header().Set("X-XSRF-TOKEN", "x"+session)
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html>login</html>"))
+	})
+	mux.HandleFunc("/api/items", func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		session := server.session
+		redirect := server.redirect
+		server.mu.Unlock()
+		cookie, err := r.Cookie("JSESSIONID")
+		if err != nil || session == "" || cookie.Value != session || r.Header.Get("X-XSRF-TOKEN") != "x"+session {
+			if redirect {
+				http.Redirect(w, r, "/login", http.StatusFound)
+				return
+			}
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("items for " + session))
+	})
+	server.Server = httptest.NewServer(mux)
+	return server
+}
+
+func (s *nsxServer) expireSession() {
+	s.mu.Lock()
+	s.session = ""
+	s.mu.Unlock()
+}
+
+func newNSXClient(url string, password string) *Client {
+	client := &Client{URL: url}
+	client.Authenticator = &SessionAuth{
+		Client:           client,
+		LoginEndpoint:    "/api/session/create",
+		LoginRequest:     map[string]string{"j_username": "nsxUser", "j_password": password},
+		LoginContentType: "application/x-www-form-urlencoded",
+		CSRFHeader:       "X-XSRF-TOKEN",
+	}
+	return client
+}
+
+func TestSessionAuth(t *testing.T) {
+
+	server := newNSXServer()
+	defer server.Close()
+
+	client := newNSXClient(server.URL, "nsxPass")
+	for i := 0; i < 3; i++ {
+		out := new(string)
+		api := NewBaseAPI(http.MethodGet, "/api/items", nil, out, nil)
+		assert.Nil(t, client.Do(api))
+		assert.Equal(t, "items for s1", *out)
+		assert.Equal(t, 1, api.Attempts())
+	}
+	assert.Equal(t, 1, server.logins)
+
+	server.expireSession()
+	out := new(string)
+	api := NewBaseAPI(http.MethodGet, "/api/items", nil, out, nil)
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, "items for s2", *out)
+	assert.Equal(t, 2, api.Attempts())
+	assert.Equal(t, 2, server.logins)
+}
+
+func TestSessionAuthDetectsRedirectToLogin(t *testing.T) {
+
+	server := newNSXServer()
+	server.redirect = true
+	defer server.Close()
+
+	client := newNSXClient(server.URL, "nsxPass")
+	auth := client.Authenticator.(*SessionAuth)
+	auth.IsExpired = func(res *http.Response) bool {
+		return res.Request.URL.Path == "/login"
+	}
+	assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/api/items", nil, new(string), nil)))
+
+	server.expireSession()
+	out := new(string)
+	api := NewBaseAPI(http.MethodGet, "/api/items", nil, out, nil)
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, "items for s2", *out)
+	assert.Equal(t, 2, api.Attempts())
+
+	auth.IsExpired = nil
+	auth.LoginEndpoint = "/login"
+	assert.True(t, auth.expired(&http.Response{
+		StatusCode: http.StatusOK,
+		Request:    &http.Request{URL: &url.URL{Path: "/login"}, Response: &http.Response{}},
+	}))
+	assert.False(t, auth.expired(&http.Response{
+		StatusCode: http.StatusOK,
+		Request:    &http.Request{URL: &url.URL{Path: "/api/items"}, Response: &http.Response{}},
+	}))
+}
+
+func TestSessionAuthReloginsOnlyOnce(t *testing.T) {
+
+	server := newNSXServer()
+	defer server.Close()
+
+	client := newNSXClient(server.URL, "nsxPass")
+	client.Authenticator.(*SessionAuth).CSRFHeader = "X-Other"
+	api := NewBaseAPI(http.MethodGet, "/api/items", nil, new(string), nil)
+	assert.True(t, errors.Is(client.Do(api), ErrForbidden))
+	assert.Equal(t, 2, api.Attempts())
+	assert.Equal(t, 2, server.logins)
+}
+
+func TestSessionAuthLoginFailure(t *testing.T) {
+
+	server := newNSXServer()
+	defer server.Close()
+
+	client := newNSXClient(server.URL, "wrong")
+	err := client.Do(NewBaseAPI(http.MethodGet, "/api/items", nil, new(string), nil))
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	assert.Equal(t, 0, server.logins)
+}
+
+func TestSessionAuthCSRFCookie(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1"})
+			http.SetCookie(w, &http.Cookie{Name: "XSRF-TOKEN", Value: "x1"})
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		session, _ := r.Cookie("session")
+		other, _ := r.Cookie("other")
+		w.Write([]byte(session.Value + "|" + other.Value + "|" + r.Header.Get("X-XSRF-TOKEN")))
+	}))
+	defer ts.Close()
+
+	client := &Client{URL: ts.URL}
+	client.Authenticator = &SessionAuth{
+		Client:        client,
+		LoginEndpoint: "/login",
+		CSRFHeader:    "X-XSRF-TOKEN",
+		CSRFCookie:    "XSRF-TOKEN",
+	}
+	out := new(string)
+	api := NewBaseAPI(http.MethodGet, "/api", nil, out, nil)
+	api.SetHeader("Cookie", "other=kept")
+	assert.Nil(t, client.Do(api))
+	assert.Equal(t, "s1|kept|x1", *out)
+}
+
+func TestSessionAuthSingleLoginInFlight(t *testing.T) {
+
+	server := newNSXServer()
+	defer server.Close()
+
+	client := newNSXClient(server.URL, "nsxPass")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out := new(string)
+			assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/api/items", nil, out, nil)))
+			assert.Equal(t, "items for s1", *out)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, server.logins)
+}
+
+func TestSessionAuthWithoutClient(t *testing.T) {
+
+	server := newNSXServer()
+	defer server.Close()
+
+	client := Client{URL: server.URL, Authenticator: &SessionAuth{LoginEndpoint: "/api/session/create"}}
+	err := client.Do(NewBaseAPI(http.MethodGet, "/api/items", nil, new(string), nil))
+	assert.True(t, errors.Is(err, ErrNoLoginClient))
+	assert.Equal(t, 0, server.logins)
+}
+
+func TestSessionLoginOutlivesCancelledCaller(t *testing.T) {
+
+	server := newNSXServer()
+	defer server.Close()
+	login := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/session/create" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		login.ServeHTTP(w, r)
+	})
+
+	client := newNSXClient(server.URL, "nsxPass")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	first := make(chan error)
+	go func() {
+		first <- client.DoContext(ctx, NewBaseAPI(http.MethodGet, "/api/items", nil, new(string), nil))
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	out := new(string)
+	assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/api/items", nil, out, nil)))
+	assert.Equal(t, "items for s1", *out)
+	assert.True(t, errors.Is(<-first, context.DeadlineExceeded))
+	assert.Equal(t, 1, server.logins)
+}
@@ -0,0 +1,476 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PayloadSigning - How the payload of a request is covered by its AWS
+// Signature Version 4.
+type PayloadSigning int
+
+const (
+	// SignedPayload - The SHA-256 hash of the payload is signed; the
+	// payload must be rewindable, as it is read once to be hashed.
+	SignedPayload PayloadSigning = iota
+	// UnsignedPayload - The payload is sent as is, not covered by the
+	// signature (X-Amz-Content-Sha256: UNSIGNED-PAYLOAD).
+	UnsignedPayload
+	// StreamingPayload - The payload is sent in aws-chunked encoding, each
+	// chunk signed as it is sent. Its length must be known.
+	StreamingPayload
+)
+
+// DefaultChunkSize - Size of the chunks of a StreamingPayload, when
+// SigV4.ChunkSize is zero.
+const DefaultChunkSize = 64 * 1024
+
+const (
+	sigV4Algorithm      = "AWS4-HMAC-SHA256"
+	sigV4ChunkAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+	unsignedPayload     = "UNSIGNED-PAYLOAD"
+	streamingPayload    = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	amzDateFormat       = "20060102T150405Z"
+	emptyPayloadHash    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// ErrPayloadNotRewindable - Returned when a SignedPayload cannot be read
+// once to be hashed and again to be sent.
+var ErrPayloadNotRewindable = errors.New("payload cannot be read twice to be signed, use UnsignedPayload or StreamingPayload")
+
+// sigV4UnsignedHeaders - Headers left out of the signature, as proxies or
+// the transport may change them.
+var sigV4UnsignedHeaders = map[string]bool{
+	"authorization":     true,
+	"user-agent":        true,
+	"x-amzn-trace-id":   true,
+	"expect":            true,
+	"connection":        true,
+	"transfer-encoding": true,
+	"content-length":    true,
+}
+
+// SigV4 - Authenticator signing requests with AWS Signature Version 4, for
+// S3-compatible services, API Gateway and the other AWS services. The
+// signature covers the final URL, the headers and the payload hash of every
+// attempt; Presign signs URLs instead.
+type SigV4 struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	Service         string
+
+	// Payload - How the payload is signed, SignedPayload by default. A
+	// X-Amz-Content-Sha256 header set on the request is used as the payload
+	// hash as is.
+	Payload PayloadSigning
+
+	// ChunkSize - Size of the chunks of a StreamingPayload,
+	// DefaultChunkSize when zero.
+	ChunkSize int
+
+	// DisableURIPathEscaping - Signs the path as sent, rather than escaped
+	// twice and normalized. Always the case for the s3 service.
+	DisableURIPathEscaping bool
+
+	now func() time.Time
+}
+
+// Authenticate - Signs req, setting its Authorization, X-Amz-Date and, when
+// needed, X-Amz-Content-Sha256 and X-Amz-Security-Token headers.
+func (s *SigV4) Authenticate(req *http.Request) error {
+	if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+		return ErrMissingCredentials
+	}
+	t := s.clock().UTC()
+
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		var err error
+		payloadHash, err = s.payloadHash(req)
+		if err != nil {
+			return err
+		}
+		if s.Service == "s3" || s.Payload != SignedPayload {
+			req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+		}
+	}
+
+	var decodedLength int64
+	if payloadHash == streamingPayload {
+		decodedLength = req.ContentLength
+		if decoded := req.Header.Get("X-Amz-Decoded-Content-Length"); decoded != "" {
+			decodedLength, _ = strconv.ParseInt(decoded, 10, 64)
+		}
+		if decodedLength < 0 {
+			return fmt.Errorf("streaming payload of unknown length cannot be signed")
+		}
+		req.Header.Set("Content-Encoding", appendContentEncoding(req.Header.Get("Content-Encoding"), "aws-chunked"))
+		req.Header.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(decodedLength, 10))
+		req.ContentLength = chunkedLength(decodedLength, s.chunkSize())
+	}
+
+	req.Header.Set("X-Amz-Date", t.Format(amzDateFormat))
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	signedHeaders, canonicalHeaders := s.canonicalHeaders(req, payloadHash == streamingPayload)
+	signature := s.signature(t, s.canonicalRequest(req, req.URL.Query(), canonicalHeaders, signedHeaders, payloadHash))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.AccessKeyID, s.scope(t), signedHeaders, signature))
+
+	if payloadHash == streamingPayload {
+		body := req.Body
+		if body == nil {
+			body = http.NoBody
+		}
+		req.Body = &awsChunkedReader{
+			body:      body,
+			key:       s.signingKey(t),
+			date:      t.Format(amzDateFormat),
+			scope:     s.scope(t),
+			previous:  signature,
+			chunkSize: s.chunkSize(),
+		}
+	}
+	return nil
+}
+
+// Presign - Returns the URL of req with its signature in the query string,
+// usable without credentials until it expires. The payload is not signed.
+func (s *SigV4) Presign(req *http.Request, expires time.Duration) (string, error) {
+	if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+		return "", ErrMissingCredentials
+	}
+	t := s.clock().UTC()
+	signedHeaders, canonicalHeaders := s.canonicalHeaders(req, false)
+
+	query := req.URL.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", s.AccessKeyID+"/"+s.scope(t))
+	query.Set("X-Amz-Date", t.Format(amzDateFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", signedHeaders)
+	if s.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	signature := s.signature(t, s.canonicalRequest(req, query, canonicalHeaders, signedHeaders, unsignedPayload))
+
+	presigned := *req.URL
+	presigned.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + signature
+	return presigned.String(), nil
+}
+
+// payloadHash - Returns the X-Amz-Content-Sha256 value of req.
+func (s *SigV4) payloadHash(req *http.Request) (string, error) {
+	switch s.Payload {
+	case UnsignedPayload:
+		return unsignedPayload, nil
+	case StreamingPayload:
+		return streamingPayload, nil
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return emptyPayloadHash, nil
+	}
+	if req.GetBody == nil {
+		return "", ErrPayloadNotRewindable
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, body)
+	body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	// Streamed and multipart bodies share their reader with GetBody, which
+	// hashing has drained: the body is rewound to be sent.
+	rewound, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	req.Body.Close()
+	req.Body = rewound
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// canonicalRequest - Returns the canonical request of req, with the given
+// query parameters.
+func (s *SigV4) canonicalRequest(req *http.Request, query url.Values, canonicalHeaders, signedHeaders, payloadHash string) string {
+	return strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req.URL),
+		canonicalQuery(query),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+}
+
+// canonicalURI - Returns the canonical path of u. The s3 service signs the
+// path escaped once, which is then also sent that way; the other services
+// sign the path as sent, normalized and escaped again.
+func (s *SigV4) canonicalURI(u *url.URL) string {
+	escapedPath := u.EscapedPath()
+	if escapedPath == "" {
+		return "/"
+	}
+	if s.DisableURIPathEscaping || s.Service == "s3" {
+		escaped := sigV4EscapePath(escapedPath)
+		if escaped != escapedPath {
+			u.RawPath = escaped
+		}
+		return escaped
+	}
+	normalized := path.Clean(escapedPath)
+	if strings.HasSuffix(escapedPath, "/") && normalized != "/" {
+		normalized += "/"
+	}
+	return sigV4Escape(normalized, false)
+}
+
+// canonicalHeaders - Returns the signed header names and the canonical
+// headers of req.
+func (s *SigV4) canonicalHeaders(req *http.Request, withContentLength bool) (string, string) {
+	headers := map[string][]string{}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if !sigV4UnsignedHeaders[name] {
+			headers[name] = append(headers[name], values...)
+		}
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers["host"] = []string{host}
+	if withContentLength {
+		headers["content-length"] = []string{strconv.FormatInt(req.ContentLength, 10)}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		values := make([]string, len(headers[name]))
+		for i, value := range headers[name] {
+			values[i] = strings.Join(strings.Fields(value), " ")
+		}
+		canonical.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+	}
+	return strings.Join(names, ";"), canonical.String()
+}
+
+// signature - Returns the signature of canonicalRequest.
+func (s *SigV4) signature(t time.Time, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		t.Format(amzDateFormat),
+		s.scope(t),
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+	return hex.EncodeToString(hmacSHA256(s.signingKey(t), stringToSign))
+}
+
+func (s *SigV4) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.Region + "/" + s.Service + "/aws4_request"
+}
+
+func (s *SigV4) signingKey(t time.Time) []byte {
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func (s *SigV4) chunkSize() int {
+	if s.ChunkSize > 0 {
+		return s.ChunkSize
+	}
+	return DefaultChunkSize
+}
+
+func (s *SigV4) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// PresignURL - Returns the URL of the call described by api, presigned by
+// signer to be usable without credentials until it expires.
+func (restClient *Client) PresignURL(api *BaseAPI, signer *SigV4, expires time.Duration) (string, error) {
+	requestURL, err := restClient.requestURL(api)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(api.Method(), requestURL, nil)
+	if err != nil {
+		return "", err
+	}
+	for headerKey, headerValues := range api.Header() {
+		req.Header[http.CanonicalHeaderKey(headerKey)] = headerValues
+	}
+	return signer.Presign(req, expires)
+}
+
+// awsChunkedReader - Encodes a payload in aws-chunked encoding, signing
+// every chunk with the signature of the previous one.
+type awsChunkedReader struct {
+	body      io.ReadCloser
+	key       []byte
+	date      string
+	scope     string
+	previous  string
+	chunkSize int
+
+	chunk   []byte
+	pending bytes.Buffer
+	done    bool
+}
+
+func (r *awsChunkedReader) Read(p []byte) (int, error) {
+	for r.pending.Len() == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if r.chunk == nil {
+			r.chunk = make([]byte, r.chunkSize)
+		}
+		n, err := io.ReadFull(r.body, r.chunk)
+		if n > 0 {
+			r.writeChunk(r.chunk[:n])
+		}
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			r.writeChunk(nil)
+			r.done = true
+		default:
+			return 0, err
+		}
+	}
+	return r.pending.Read(p)
+}
+
+func (r *awsChunkedReader) Close() error {
+	return r.body.Close()
+}
+
+func (r *awsChunkedReader) writeChunk(data []byte) {
+	stringToSign := strings.Join([]string{
+		sigV4ChunkAlgorithm,
+		r.date,
+		r.scope,
+		r.previous,
+		emptyPayloadHash,
+		sha256Hex(data),
+	}, "\n")
+	r.previous = hex.EncodeToString(hmacSHA256(r.key, stringToSign))
+	fmt.Fprintf(&r.pending, "%x;chunk-signature=%s\r\n", len(data), r.previous)
+	r.pending.Write(data)
+	r.pending.WriteString("\r\n")
+}
+
+// chunkedLength - Returns the length of a payload of decodedLength bytes in
+// aws-chunked encoding.
+func chunkedLength(decodedLength int64, chunkSize int) int64 {
+	frame := func(n int64) int64 {
+		return int64(len(strconv.FormatInt(n, 16))+len(";chunk-signature=")+64+2) + n + 2
+	}
+	size := int64(chunkSize)
+	length := decodedLength / size * frame(size)
+	if rest := decodedLength % size; rest > 0 {
+		length += frame(rest)
+	}
+	return length + frame(0)
+}
+
+func appendContentEncoding(contentEncoding, encoding string) string {
+	for _, existing := range strings.Split(contentEncoding, ",") {
+		if strings.TrimSpace(existing) == encoding {
+			return contentEncoding
+		}
+	}
+	if contentEncoding == "" {
+		return encoding
+	}
+	return encoding + "," + contentEncoding
+}
+
+// canonicalQuery - Returns the query parameters escaped and sorted by name,
+// then value.
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, sigV4Escape(name, true)+"="+sigV4Escape(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// sigV4Escape - Percent-encodes every byte of s but the unreserved
+// characters of RFC 3986, and "/" unless escapeSlash is set.
+func sigV4Escape(s string, escapeSlash bool) string {
+	var escaped strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !escapeSlash:
+			escaped.WriteByte(c)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+// sigV4EscapePath - Percent-encodes the bytes of an already escaped path
+// that sigV4Escape would, keeping its escape sequences (such as %2F) as they
+// are.
+func sigV4EscapePath(escapedPath string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(escapedPath); i++ {
+		if escapedPath[i] == '%' && i+2 < len(escapedPath) {
+			escaped.WriteString(strings.ToUpper(escapedPath[i : i+3]))
+			i += 2
+			continue
+		}
+		escaped.WriteString(sigV4Escape(escapedPath[i:i+1], false))
+	}
+	return escaped.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
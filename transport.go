@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultMaxIdleConnsPerHost - Number of idle keep-alive connections kept
+	// per host when Client.MaxIdleConnsPerHost is not set.
+	DefaultMaxIdleConnsPerHost = 10
+
+	// DefaultIdleConnTimeout - How long an idle connection is kept in the pool
+	// when Client.IdleConnTimeout is not set.
+	DefaultIdleConnTimeout = 90 * time.Second
+
+	// DefaultTLSSessionCacheSize - Number of TLS sessions cached for
+	// resumption when Client.TLSSessionCacheSize is not set.
+	DefaultTLSSessionCacheSize = 64
+)
+
+// getHTTPClient - Returns the http.Client shared by every call made through
+// restClient, building it on first use.
+func (restClient *Client) getHTTPClient() *http.Client {
+	restClient.httpClientOnce.Do(func() {
+		if restClient.HTTPClient != nil {
+			restClient.httpClient = restClient.HTTPClient
+			return
+		}
+		transport := restClient.Transport
+		if transport == nil {
+			transport = restClient.newTransport()
+		}
+		restClient.httpClient = &http.Client{
+			Transport: transport,
+			Timeout:   restClient.Timeout * time.Second,
+		}
+	})
+	return restClient.httpClient
+}
+
+// newTransport - Builds the pooled transport used when no RoundTripper has
+// been injected.
+func (restClient *Client) newTransport() *http.Transport {
+	maxIdlePerHost := restClient.MaxIdleConnsPerHost
+	if maxIdlePerHost <= 0 {
+		maxIdlePerHost = DefaultMaxIdleConnsPerHost
+	}
+	idleTimeout := restClient.IdleConnTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleConnTimeout
+	}
+	sessionCacheSize := restClient.TLSSessionCacheSize
+	if sessionCacheSize <= 0 {
+		sessionCacheSize = DefaultTLSSessionCacheSize
+	}
+
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: restClient.IgnoreSSL,
+			ClientSessionCache: tls.NewLRUClientSessionCache(sessionCacheSize),
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          maxIdlePerHost * 10,
+		MaxIdleConnsPerHost:   maxIdlePerHost,
+		IdleConnTimeout:       idleTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
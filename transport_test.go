@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConnectionsAreReused(t *testing.T) {
+
+	var newConns int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("pong"))
+	}))
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&newConns, 1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	client := Client{URL: ts.URL}
+	for i := 0; i < 5; i++ {
+		api := NewBaseAPI(http.MethodGet, "/", nil, new(string), nil)
+		assert.Nil(t, client.Do(api))
+		assert.Equal(t, "pong", *api.ResponseObject().(*string))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&newConns))
+}
+
+type countingRoundTripper struct {
+	calls int32
+	next  http.RoundTripper
+}
+
+func (rt *countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&rt.calls, 1)
+	return rt.next.RoundTrip(req)
+}
+
+func TestInjectedTransport(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	rt := &countingRoundTripper{next: http.DefaultTransport}
+	client := Client{URL: ts.URL, Transport: rt}
+	assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/", nil, nil, nil)))
+	assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/", nil, nil, nil)))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&rt.calls))
+}
+
+func TestInjectedHTTPClient(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	rt := &countingRoundTripper{next: http.DefaultTransport}
+	client := Client{URL: ts.URL, HTTPClient: &http.Client{Transport: rt}}
+	assert.Nil(t, client.Do(NewBaseAPI(http.MethodGet, "/", nil, nil, nil)))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&rt.calls))
+}